@@ -1,17 +1,36 @@
 package main
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
+	"tringldev-server/internal/activitypub"
+	"tringldev-server/internal/albumart"
 	"tringldev-server/internal/blog"
-
+	"tringldev-server/internal/cards"
 	"tringldev-server/internal/config"
 	"tringldev-server/internal/contact"
+	"tringldev-server/internal/content"
+	"tringldev-server/internal/discoveries"
+	"tringldev-server/internal/durations"
+	"tringldev-server/internal/export"
+	"tringldev-server/internal/genres"
 	"tringldev-server/internal/github"
 	"tringldev-server/internal/lastfm"
+	"tringldev-server/internal/listenbrainz"
 	"tringldev-server/internal/middleware"
+	"tringldev-server/internal/milestones"
+	"tringldev-server/internal/nowplaying"
+	"tringldev-server/internal/ogimage"
+	"tringldev-server/internal/scrobbles"
+	"tringldev-server/internal/webmention"
+	"tringldev-server/internal/wrapped"
 
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/cors"
@@ -21,9 +40,34 @@ import (
 func main() {
 	cfg := config.Load()
 
-	lastfmService := lastfm.NewService(cfg)
+	artService, err := albumart.NewService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialise album art cache: %v\n", err)
+	}
+
+	// Album art is served through /api/art so visitors never load images from Last.fm
+	lastfmUncached := lastfm.NewService(cfg)
+	lastfmUncached.SetAlbumArtRewriter(artService.Rewrite)
+
+	// The archive, genres, wrapped reports and loved tracks always come from Last.fm
+	var provider lastfm.Provider = lastfmUncached
+	if cfg.MusicProvider == "listenbrainz" {
+		provider = listenbrainz.NewService(cfg, nil)
+	}
+	lastfmService := lastfm.NewCachedService(provider, lastfm.DefaultCacheTTLs)
+
+	// The music wall always reads Last.fm, sharing the main cache when that is the provider
+	var primaryUser *lastfm.CachedService
+	if cfg.MusicProvider == "lastfm" {
+		primaryUser = lastfmService
+	}
+	lastfmUsers := lastfm.NewUsers(lastfmUncached, primaryUser, cfg.LastFMUsers, lastfm.DefaultCacheTTLs)
 	githubService := github.NewService(cfg)
 	contactService := contact.NewService(cfg)
+	ogService, err := ogimage.NewService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialise og image renderer: %v\n", err)
+	}
 
 	app := iris.New()
 
@@ -31,6 +75,60 @@ func main() {
 		log.Fatalf("Failed to initialise database: %v\n", err)
 	}
 
+	webmentionService := webmention.NewService(cfg, nil)
+	webmentionService.Start()
+	blog.OnPublish(func(post *blog.Post) {
+		go webmentionService.SendForPost(post)
+	})
+
+	activitypubService, err := activitypub.NewService(cfg, blog.DB, nil)
+	if err != nil {
+		log.Fatalf("Failed to initialise activitypub: %v\n", err)
+	}
+	blog.OnPublish(func(post *blog.Post) {
+		go activitypubService.DeliverPost(post)
+	})
+
+	// Publish hooks must be registered first so synced posts are announced
+	contentSyncer := content.NewSyncer(cfg)
+	contentSyncer.Start()
+
+	scrobbleArchive, err := scrobbles.NewArchive(cfg, blog.DB, lastfmUncached)
+	if err != nil {
+		log.Fatalf("Failed to initialise scrobble archive: %v\n", err)
+	}
+	scrobbleArchive.Start()
+
+	genreService, err := genres.NewService(cfg, blog.DB, lastfmUncached)
+	if err != nil {
+		log.Fatalf("Failed to initialise genre tags: %v\n", err)
+	}
+
+	durationService, err := durations.NewService(blog.DB, lastfmUncached)
+	if err != nil {
+		log.Fatalf("Failed to initialise track durations: %v\n", err)
+	}
+
+	discoveryService := discoveries.NewService(lastfmUncached, scrobbleArchive)
+
+	wrappedService, err := wrapped.NewService(cfg, blog.DB, lastfmUncached, genreService, scrobbleArchive)
+	if err != nil {
+		log.Fatalf("Failed to initialise wrapped reports: %v\n", err)
+	}
+
+	milestoneWatcher, err := milestones.NewWatcher(cfg, blog.DB, lastfmUncached, contactService)
+	if err != nil {
+		log.Fatalf("Failed to initialise milestone watcher: %v\n", err)
+	}
+	milestoneWatcher.Start()
+
+	scrobbleExporter := export.NewExporter(lastfmUncached)
+
+	cardService := cards.NewService(artService)
+
+	nowPlayingHub := nowplaying.NewHub(cfg, lastfmService)
+	nowPlayingHub.Start()
+
 	// CORS middleware - use configured allowed origins
 	allowedOrigins := cfg.AllowedOrigins
 	if len(allowedOrigins) == 0 {
@@ -49,6 +147,9 @@ func main() {
 
 	contactLimiter := middleware.NewRateLimiter(12*time.Second, 5)
 
+	// Pages show dozens of covers at once, so images get a much larger allowance
+	artLimiter := middleware.NewRateLimiter(100*time.Millisecond, 60)
+
 	app.Get("/", func(ctx iris.Context) {
 		err := ctx.JSON(iris.Map{
 			"status":  "ok",
@@ -63,10 +164,11 @@ func main() {
 
 	// Last.fm endpoint - Get currently playing song
 	app.Get("/api/now-playing", generalLimiter.Handler(), func(ctx iris.Context) {
-		nowPlaying, err := lastfmService.GetCurrentlyPlaying()
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		nowPlaying, err := lastfmService.GetCurrentlyPlaying(reqCtx)
 		if err != nil {
 			log.Printf("Error fetching now playing: %v\n", err)
-			ctx.StatusCode(iris.StatusInternalServerError)
+			ctx.StatusCode(lastfmErrorStatus(err))
 			err := ctx.JSON(iris.Map{
 				"error": "Failed to fetch currently playing song",
 			})
@@ -76,12 +178,108 @@ func main() {
 			return
 		}
 
+		setCacheHeaders(ctx, cacheStatus)
 		err = ctx.JSON(nowPlaying)
 		if err != nil {
 			log.Printf("Failed to send response: %v\n", err)
 		}
 	})
 
+	// Last.fm endpoint - Stream now playing changes as Server-Sent Events
+	app.Get("/api/now-playing/stream", generalLimiter.Handler(), iris.FromStd(nowPlayingHub.ServeSSE))
+
+	// Last.fm endpoint - Stream now playing changes over a WebSocket
+	app.Get("/api/now-playing/ws", generalLimiter.Handler(), iris.FromStd(nowPlayingHub.ServeWebSocket))
+
+	// Last.fm endpoint - What every allow-listed user is playing, failures reported per user
+	app.Get("/api/now-playing/all", generalLimiter.Handler(), func(ctx iris.Context) {
+		results := lastfmUsers.NowPlayingAll(ctx.Request().Context())
+
+		failed := 0
+		var firstErr error
+		for _, result := range results {
+			if result.Err() != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = result.Err()
+				}
+				log.Printf("Error fetching now playing for %s: %v\n", result.Username, result.Err())
+			}
+		}
+
+		if len(results) > 0 && failed == len(results) {
+			ctx.StatusCode(lastfmErrorStatus(firstErr))
+		} else {
+			ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(lastfm.DefaultCacheTTLs.NowPlaying.Seconds())))
+		}
+		err := ctx.JSON(iris.Map{
+			"users":  results,
+			"failed": failed,
+		})
+		if err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
+	// Last.fm endpoint - Get an allow-listed user's currently playing song
+	app.Get("/api/users/{user:string}/now-playing", generalLimiter.Handler(), func(ctx iris.Context) {
+		userService, ok := lastfmUsers.Get(ctx.Params().Get("user"))
+		if !ok {
+			ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": "Unknown user"})
+			return
+		}
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		nowPlaying, err := userService.GetCurrentlyPlaying(reqCtx)
+		if err != nil {
+			log.Printf("Error fetching now playing for %s: %v\n", ctx.Params().Get("user"), err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to fetch currently playing song"})
+			return
+		}
+
+		setCacheHeaders(ctx, cacheStatus)
+		if err := ctx.JSON(nowPlaying); err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
+	// Last.fm endpoint - Get an allow-listed user's top artists
+	app.Get("/api/users/{user:string}/top-artists", generalLimiter.Handler(), func(ctx iris.Context) {
+		userService, ok := lastfmUsers.Get(ctx.Params().Get("user"))
+		if !ok {
+			ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": "Unknown user"})
+			return
+		}
+
+		limit := ctx.URLParamIntDefault("limit", 10)
+		period, chartRange, ok := chartPeriod(ctx)
+		if !ok {
+			return
+		}
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		var topArtists *lastfm.TopArtistsInfo
+		var err error
+		if chartRange.From.IsZero() {
+			topArtists, err = userService.GetTopArtists(reqCtx, limit, period)
+		} else {
+			topArtists, err = userService.GetTopArtistsRange(reqCtx, limit, chartRange.From, chartRange.To)
+		}
+		if err != nil {
+			log.Printf("Error fetching top artists for %s: %v\n", ctx.Params().Get("user"), err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to fetch top artists"})
+			return
+		}
+
+		setCacheHeaders(ctx, cacheStatus)
+		err = ctx.JSON(lastfm.TopArtistsInfo{
+			Artists: genreService.Enrich(ctx.Request().Context(), topArtists.Artists),
+		})
+		if err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
 	// Last.fm endpoint - Get top weekly artists
 	app.Get("/api/top-artists", generalLimiter.Handler(), func(ctx iris.Context) {
 		// Optional: Get limit and period from query parameters
@@ -94,12 +292,22 @@ func main() {
 			}
 		}
 
-		period := ctx.URLParamDefault("period", "7day") // weekly, monthly, yearly, alltime
+		period, chartRange, ok := chartPeriod(ctx)
+		if !ok {
+			return
+		}
 
-		topArtists, err := lastfmService.GetTopArtists(limit, period)
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		var topArtists *lastfm.TopArtistsInfo
+		var err error
+		if chartRange.From.IsZero() {
+			topArtists, err = lastfmService.GetTopArtists(reqCtx, limit, period)
+		} else {
+			topArtists, err = lastfmService.GetTopArtistsRange(reqCtx, limit, chartRange.From, chartRange.To)
+		}
 		if err != nil {
 			log.Printf("Error fetching top artists: %v\n", err)
-			ctx.StatusCode(iris.StatusInternalServerError)
+			ctx.StatusCode(lastfmErrorStatus(err))
 			err := ctx.JSON(iris.Map{
 				"error": "Failed to fetch top artists",
 			})
@@ -109,8 +317,93 @@ func main() {
 			return
 		}
 
-		err = ctx.JSON(topArtists)
+		setCacheHeaders(ctx, cacheStatus)
+		err = ctx.JSON(lastfm.TopArtistsInfo{
+			Artists: genreService.Enrich(ctx.Request().Context(), topArtists.Artists),
+		})
+		if err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
+	// Last.fm endpoint - Share of listening per genre, from the top artists' tags
+	app.Get("/api/genres", generalLimiter.Handler(), func(ctx iris.Context) {
+		period := ctx.URLParamDefault("period", "7day")
+		if !lastfm.ValidPeriod(period) {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{
+				"error":        fmt.Sprintf("Invalid period %q", period),
+				"validPeriods": lastfm.Periods,
+			})
+			return
+		}
+		limit := ctx.URLParamIntDefault("limit", 20)
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		topArtists, err := lastfmService.GetTopArtists(reqCtx, 50, period)
+		if err != nil {
+			log.Printf("Error fetching top artists for genres: %v\n", err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to fetch genres"})
+			return
+		}
+
+		setCacheHeaders(ctx, cacheStatus)
+		ctx.JSON(iris.Map{
+			"period": period,
+			"genres": genreService.Genres(ctx.Request().Context(), topArtists.Artists, limit),
+		})
+	})
+
+	// Last.fm endpoint - Rank changes of top artists between two consecutive windows
+	app.Get("/api/trends", generalLimiter.Handler(), func(ctx iris.Context) {
+		current := ctx.URLParamDefault("current", "7day")
+		previous := ctx.URLParamDefault("previous", current)
+		for _, period := range []string{current, previous} {
+			if !lastfm.ValidTrendPeriod(period) {
+				ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{
+					"error":        fmt.Sprintf("Invalid period %q", period),
+					"validPeriods": lastfm.TrendPeriods,
+				})
+				return
+			}
+		}
+		limit := ctx.URLParamIntDefault("limit", 10)
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		trends, err := lastfmService.GetArtistTrends(reqCtx, limit, current, previous)
+		if err != nil {
+			log.Printf("Error fetching trends: %v\n", err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to fetch trends"})
+			return
+		}
+
+		setCacheHeaders(ctx, cacheStatus)
+		if err := ctx.JSON(trends); err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
+	// Last.fm endpoint - Bio, similar artists and your plays of one artist. A path parameter
+	// so names with slashes (AC/DC) work once encoded.
+	app.Get("/api/artists/{name:path}", generalLimiter.Handler(), func(ctx iris.Context) {
+		name := strings.TrimSpace(ctx.Params().Get("name"))
+		if name == "" || len(name) > 200 {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": "Artist name must be 1 to 200 characters"})
+			return
+		}
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		artist, err := lastfmService.GetArtistDetail(reqCtx, name)
 		if err != nil {
+			log.Printf("Error fetching artist %q: %v\n", name, err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to fetch artist"})
+			return
+		}
+
+		setCacheHeaders(ctx, cacheStatus)
+		if err := ctx.JSON(artist); err != nil {
 			log.Printf("Failed to send response: %v\n", err)
 		}
 	})
@@ -126,12 +419,22 @@ func main() {
 			}
 		}
 
-		period := ctx.URLParamDefault("period", "7day")
+		period, chartRange, ok := chartPeriod(ctx)
+		if !ok {
+			return
+		}
 
-		topTracks, err := lastfmService.GetTopTracks(limit, period)
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		var topTracks *lastfm.TopTracksInfo
+		var err error
+		if chartRange.From.IsZero() {
+			topTracks, err = lastfmService.GetTopTracks(reqCtx, limit, period)
+		} else {
+			topTracks, err = lastfmService.GetTopTracksRange(reqCtx, limit, chartRange.From, chartRange.To)
+		}
 		if err != nil {
 			log.Printf("Error fetching top tracks: %v\n", err)
-			ctx.StatusCode(iris.StatusInternalServerError)
+			ctx.StatusCode(lastfmErrorStatus(err))
 			err := ctx.JSON(iris.Map{
 				"error": "Failed to fetch top tracks",
 			})
@@ -141,6 +444,7 @@ func main() {
 			return
 		}
 
+		setCacheHeaders(ctx, cacheStatus)
 		err = ctx.JSON(topTracks)
 		if err != nil {
 			log.Printf("Failed to send response: %v\n", err)
@@ -158,12 +462,22 @@ func main() {
 			}
 		}
 
-		period := ctx.URLParamDefault("period", "7day")
+		period, chartRange, ok := chartPeriod(ctx)
+		if !ok {
+			return
+		}
 
-		topAlbums, err := lastfmService.GetTopAlbums(limit, period)
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		var topAlbums *lastfm.TopAlbumsInfo
+		var err error
+		if chartRange.From.IsZero() {
+			topAlbums, err = lastfmService.GetTopAlbums(reqCtx, limit, period)
+		} else {
+			topAlbums, err = lastfmService.GetTopAlbumsRange(reqCtx, limit, chartRange.From, chartRange.To)
+		}
 		if err != nil {
 			log.Printf("Error fetching top albums: %v\n", err)
-			ctx.StatusCode(iris.StatusInternalServerError)
+			ctx.StatusCode(lastfmErrorStatus(err))
 			err := ctx.JSON(iris.Map{
 				"error": "Failed to fetch top albums",
 			})
@@ -173,6 +487,7 @@ func main() {
 			return
 		}
 
+		setCacheHeaders(ctx, cacheStatus)
 		err = ctx.JSON(topAlbums)
 		if err != nil {
 			log.Printf("Failed to send response: %v\n", err)
@@ -190,10 +505,11 @@ func main() {
 			}
 		}
 
-		recentTracks, err := lastfmService.GetRecentTracks(limit)
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		recentTracks, err := lastfmService.GetRecentTracks(reqCtx, limit)
 		if err != nil {
 			log.Printf("Error fetching recent tracks: %v\n", err)
-			ctx.StatusCode(iris.StatusInternalServerError)
+			ctx.StatusCode(lastfmErrorStatus(err))
 			err := ctx.JSON(iris.Map{
 				"error": "Failed to fetch recent tracks",
 			})
@@ -203,18 +519,52 @@ func main() {
 			return
 		}
 
+		setCacheHeaders(ctx, cacheStatus)
 		err = ctx.JSON(recentTracks)
 		if err != nil {
 			log.Printf("Failed to send response: %v\n", err)
 		}
 	})
 
+	// Last.fm endpoint - Get loved tracks, one page at a time
+	app.Get("/api/loved-tracks", generalLimiter.Handler(), func(ctx iris.Context) {
+		page := ctx.URLParamIntDefault("page", 1)
+		if page <= 0 {
+			page = 1
+		}
+		limit := ctx.URLParamIntDefault("limit", 50)
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		lovedTracks, err := lastfmService.GetLovedTracks(reqCtx, page, limit)
+		if err != nil {
+			log.Printf("Error fetching loved tracks: %v\n", err)
+			ctx.StatusCode(lastfmErrorStatus(err))
+			err := ctx.JSON(iris.Map{
+				"error": "Failed to fetch loved tracks",
+			})
+			if err != nil {
+				log.Printf("Failed to send error response: %v\n", err)
+			}
+			return
+		}
+
+		setCacheHeaders(ctx, cacheStatus)
+		err = ctx.JSON(lovedTracks)
+		if err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
 	// Last.fm endpoint - Get listening stats
 	app.Get("/api/stats", generalLimiter.Handler(), func(ctx iris.Context) {
-		stats, err := lastfmService.GetListeningStats()
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		stats, err := lastfmService.GetListeningStats(reqCtx)
 		if err != nil {
 			log.Printf("Error fetching listening stats: %v\n", err)
-			ctx.StatusCode(iris.StatusInternalServerError)
+			ctx.StatusCode(lastfmErrorStatus(err))
 			err := ctx.JSON(iris.Map{
 				"error": "Failed to fetch listening stats",
 			})
@@ -224,12 +574,181 @@ func main() {
 			return
 		}
 
+		setCacheHeaders(ctx, cacheStatus)
 		err = ctx.JSON(stats)
 		if err != nil {
 			log.Printf("Failed to send response: %v\n", err)
 		}
 	})
 
+	// Last.fm endpoint - Estimated listening time from track lengths
+	app.Get("/api/stats/listening-time", generalLimiter.Handler(), func(ctx iris.Context) {
+		period := ctx.URLParamDefault("period", "overall")
+		if !lastfm.ValidPeriod(period) {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{
+				"error":        fmt.Sprintf("Invalid period %q", period),
+				"validPeriods": lastfm.Periods,
+			})
+			return
+		}
+
+		listening, err := durationService.ListeningTime(ctx.Request().Context(), period)
+		if err != nil {
+			log.Printf("Error estimating listening time: %v\n", err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to estimate listening time"})
+			return
+		}
+
+		ctx.Header("Cache-Control", "public, max-age=3600")
+		if err := ctx.JSON(listening); err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
+	// Last.fm endpoint - Artists first scrobbled within the period, most played first
+	app.Get("/api/discoveries", generalLimiter.Handler(), func(ctx iris.Context) {
+		days, err := discoveries.ParsePeriod(ctx.URLParamDefault("period", "30d"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		limit := ctx.URLParamIntDefault("limit", 50)
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		found, err := discoveryService.Discoveries(ctx.Request().Context(), days, limit)
+		if err != nil {
+			log.Printf("Error finding discoveries: %v\n", err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to find discoveries"})
+			return
+		}
+
+		ctx.Header("Cache-Control", "public, max-age=3600")
+		if err := ctx.JSON(found); err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
+	// Last.fm endpoint - Year in review built from weekly charts
+	app.Get("/api/wrapped/{year:int}", generalLimiter.Handler(), func(ctx iris.Context) {
+		year, _ := ctx.Params().GetInt("year")
+
+		report, err := wrappedService.Report(ctx.Request().Context(), year)
+		if err != nil {
+			switch {
+			case errors.Is(err, wrapped.ErrInvalidYear):
+				ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			case errors.Is(err, wrapped.ErrPending):
+				ctx.Header("Retry-After", "30")
+				ctx.StopWithJSON(iris.StatusAccepted, iris.Map{"status": "building", "message": err.Error()})
+			default:
+				log.Printf("Error building wrapped report: %v\n", err)
+				ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to build wrapped report"})
+			}
+			return
+		}
+
+		if report.Complete {
+			ctx.Header("Cache-Control", "public, max-age=86400")
+		} else {
+			ctx.Header("Cache-Control", "public, max-age=3600")
+		}
+		ctx.JSON(report)
+	})
+
+	// SVG card of the current or last played track, for embedding in READMEs
+	app.Get("/api/cards/now-playing.svg", generalLimiter.Handler(), func(ctx iris.Context) {
+		theme, err := cardTheme(ctx)
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		nowPlaying, err := lastfmService.GetCurrentlyPlaying(ctx.Request().Context())
+		if err != nil {
+			log.Printf("Error fetching now playing for card: %v\n", err)
+			writeCard(ctx, cardService.Error("Couldn't reach Last.fm", theme), 0)
+			return
+		}
+
+		card, err := cardService.NowPlaying(ctx.Request().Context(), nowPlaying, theme)
+		if err != nil {
+			log.Printf("Error rendering now playing card: %v\n", err)
+			ctx.StopWithStatus(iris.StatusInternalServerError)
+			return
+		}
+		// Always revalidated so the card follows along with what's playing
+		writeCard(ctx, card, 0)
+	})
+
+	// SVG card of the top artists for a period, for embedding in READMEs
+	app.Get("/api/cards/top-artists.svg", generalLimiter.Handler(), func(ctx iris.Context) {
+		theme, err := cardTheme(ctx)
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		limit := ctx.URLParamIntDefault("limit", 5)
+		if limit <= 0 || limit > 10 {
+			limit = 5
+		}
+		period := ctx.URLParamDefault("period", "7day")
+		if !lastfm.ValidPeriod(period) {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{
+				"error":        fmt.Sprintf("Invalid period %q", period),
+				"validPeriods": lastfm.Periods,
+			})
+			return
+		}
+
+		reqCtx, cacheStatus := lastfm.WithCacheStatus(ctx.Request().Context())
+		topArtists, err := lastfmService.GetTopArtists(reqCtx, limit, period)
+		if err != nil {
+			log.Printf("Error fetching top artists for card: %v\n", err)
+			writeCard(ctx, cardService.Error("Couldn't reach Last.fm", theme), 0)
+			return
+		}
+
+		card, err := cardService.TopArtists(topArtists, period, theme)
+		if err != nil {
+			log.Printf("Error rendering top artists card: %v\n", err)
+			ctx.StopWithStatus(iris.StatusInternalServerError)
+			return
+		}
+		writeCard(ctx, card, cacheStatus.MaxAge-cacheStatus.Age)
+	})
+
+	// Album art proxy - resized Last.fm artwork served from the local cache
+	app.Get("/api/art/{hash:string}", artLimiter.Handler(), func(ctx iris.Context) {
+		img, err := artService.Get(ctx.Request().Context(), ctx.Params().Get("hash"), ctx.URLParamIntDefault("size", 0))
+		if errors.Is(err, albumart.ErrNotFound) {
+			img, err = artService.Get(ctx.Request().Context(), albumart.PlaceholderID, ctx.URLParamIntDefault("size", 0))
+		}
+		if err != nil {
+			if errors.Is(err, albumart.ErrInvalidID) {
+				ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": "Album art not found"})
+				return
+			}
+			log.Printf("Error fetching album art: %v\n", err)
+			ctx.StopWithJSON(iris.StatusBadGateway, iris.Map{"error": "Failed to fetch album art"})
+			return
+		}
+
+		// Last.fm image ids are content hashes, so real art never changes
+		if img.Placeholder {
+			ctx.Header("Cache-Control", "public, max-age=86400")
+		} else {
+			ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		ctx.ContentType(img.ContentType)
+		if _, err := ctx.Write(img.Data); err != nil {
+			log.Printf("Failed to send album art: %v\n", err)
+		}
+	})
+
 	// GitHub endpoint - Get pinned repository
 	app.Get("/api/pinned-repo", generalLimiter.Handler(), func(ctx iris.Context) {
 		// Optional: Get specific repo name from query parameter
@@ -262,6 +781,27 @@ func main() {
 		}
 	})
 
+	// GitHub endpoint - Get every pinned repository, in profile order
+	app.Get("/api/pinned-repos", generalLimiter.Handler(), func(ctx iris.Context) {
+		repos, err := githubService.GetPinnedRepositories()
+		if err != nil {
+			log.Printf("Error fetching pinned repos: %v\n", err)
+			ctx.StatusCode(iris.StatusInternalServerError)
+			err := ctx.JSON(iris.Map{
+				"error": "Failed to fetch pinned repositories",
+			})
+			if err != nil {
+				log.Printf("Failed to send error response: %v\n", err)
+			}
+			return
+		}
+
+		err = ctx.JSON(repos)
+		if err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	})
+
 	// GitHub endpoint - Get any public repository by name
 	app.Get("/api/repo/{name:string}", generalLimiter.Handler(), func(ctx iris.Context) {
 		repoName := ctx.Params().Get("name")
@@ -361,14 +901,444 @@ func main() {
 			}
 			return
 		}
+		post.OGImage = ogService.URL(post)
 		ctx.JSON(post)
 	})
 
+	// Social preview image for a blog post, cached on disk per revision
+	app.Get("/api/blogs/{id:int}/og.png", generalLimiter.Handler(), func(ctx iris.Context) {
+		id, _ := ctx.Params().GetInt("id")
+		post, err := blog.GetBlogByID(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ctx.StopWithStatus(iris.StatusNotFound)
+			} else {
+				ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": err.Error()})
+			}
+			return
+		}
+
+		path, err := ogService.Path(post)
+		if err != nil {
+			log.Printf("Error rendering og image for blog %d: %v\n", id, err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to render preview image"})
+			return
+		}
+
+		ctx.ContentType("image/png")
+		ctx.Header("Cache-Control", "public, max-age=86400")
+		if err := ctx.ServeFile(path); err != nil {
+			log.Printf("Failed to send og image: %v\n", err)
+		}
+	})
+
+	// Webmention receiver - verification happens in the background
+	app.Post("/api/webmention", generalLimiter.Handler(), func(ctx iris.Context) {
+		source := ctx.PostValue("source")
+		target := ctx.PostValue("target")
+
+		err := webmentionService.Receive(source, target)
+		switch {
+		case err == nil:
+			ctx.StatusCode(iris.StatusAccepted)
+			ctx.JSON(iris.Map{"status": "accepted"})
+		case errors.Is(err, webmention.ErrInvalidMention):
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+		case errors.Is(err, webmention.ErrQueueFull), errors.Is(err, webmention.ErrDisabled):
+			ctx.StopWithJSON(iris.StatusServiceUnavailable, iris.Map{"error": err.Error()})
+		default:
+			log.Printf("Error receiving webmention: %v\n", err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to accept webmention"})
+		}
+	})
+
+	// Verified webmentions for a blog post
+	app.Get("/api/blogs/{id:int}/webmentions", generalLimiter.Handler(), func(ctx iris.Context) {
+		id, _ := ctx.Params().GetInt("id")
+		mentions, err := blog.GetWebmentions(id)
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": err.Error()})
+			return
+		}
+		ctx.JSON(mentions)
+	})
+
+	// ActivityPub - WebFinger lookup for @user@domain
+	app.Get("/.well-known/webfinger", generalLimiter.Handler(), func(ctx iris.Context) {
+		finger, err := activitypubService.WebFinger(ctx.URLParam("resource"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": err.Error()})
+			return
+		}
+		ctx.ContentType("application/jrd+json")
+		writeRawJSON(ctx, finger)
+	})
+
+	// ActivityPub - actor document with the key used to sign deliveries
+	app.Get("/api/activitypub/actor", generalLimiter.Handler(), func(ctx iris.Context) {
+		actor, err := activitypubService.Actor()
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": err.Error()})
+			return
+		}
+		ctx.ContentType(activitypub.ContentType)
+		writeRawJSON(ctx, actor)
+	})
+
+	// ActivityPub - every post as a Create activity
+	app.Get("/api/activitypub/outbox", generalLimiter.Handler(), func(ctx iris.Context) {
+		outbox, err := activitypubService.Outbox()
+		if err != nil {
+			if errors.Is(err, activitypub.ErrDisabled) {
+				ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": err.Error()})
+			} else {
+				log.Printf("Error building outbox: %v\n", err)
+				ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to build outbox"})
+			}
+			return
+		}
+		ctx.ContentType(activitypub.ContentType)
+		writeRawJSON(ctx, outbox)
+	})
+
+	// ActivityPub - followers collection
+	app.Get("/api/activitypub/followers", generalLimiter.Handler(), func(ctx iris.Context) {
+		followers, err := activitypubService.Followers()
+		if err != nil {
+			if errors.Is(err, activitypub.ErrDisabled) {
+				ctx.StopWithJSON(iris.StatusNotFound, iris.Map{"error": err.Error()})
+			} else {
+				log.Printf("Error listing followers: %v\n", err)
+				ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to list followers"})
+			}
+			return
+		}
+		ctx.ContentType(activitypub.ContentType)
+		writeRawJSON(ctx, followers)
+	})
+
+	// ActivityPub - a single post as an Article object
+	app.Get("/api/activitypub/posts/{id:int}", generalLimiter.Handler(), func(ctx iris.Context) {
+		if !activitypubService.Enabled() {
+			ctx.StopWithStatus(iris.StatusNotFound)
+			return
+		}
+
+		id, _ := ctx.Params().GetInt("id")
+		post, err := blog.GetBlogByID(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ctx.StopWithStatus(iris.StatusNotFound)
+			} else {
+				ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": err.Error()})
+			}
+			return
+		}
+
+		article := activitypubService.Article(post)
+		article.Context = []string{"https://www.w3.org/ns/activitystreams"}
+		ctx.ContentType(activitypub.ContentType)
+		writeRawJSON(ctx, article)
+	})
+
+	// ActivityPub - inbox accepting Follow and Undo
+	app.Post("/api/activitypub/inbox", generalLimiter.Handler(), func(ctx iris.Context) {
+		body, err := ctx.GetBody()
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": "Failed to read body"})
+			return
+		}
+
+		err = activitypubService.HandleInbox(ctx.Request(), body)
+		switch {
+		case err == nil:
+			ctx.StatusCode(iris.StatusAccepted)
+		case errors.Is(err, activitypub.ErrDisabled):
+			ctx.StopWithStatus(iris.StatusNotFound)
+		case errors.Is(err, activitypub.ErrBadSignature):
+			log.Printf("Rejected inbox delivery: %v\n", err)
+			ctx.StopWithJSON(iris.StatusUnauthorized, iris.Map{"error": err.Error()})
+		case errors.Is(err, activitypub.ErrBadActivity):
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+		default:
+			log.Printf("Error handling inbox activity: %v\n", err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to process activity"})
+		}
+	})
+
+	// Scrobble archive analytics - scrobbles per day, week or month
+	app.Get("/api/history/scrobbles", generalLimiter.Handler(), func(ctx iris.Context) {
+		r, err := scrobbles.ParseRange(ctx.URLParam("from"), ctx.URLParam("to"), ctx.URLParam("tz"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		counts, err := scrobbleArchive.ScrobblesPer(ctx.URLParamDefault("interval", "day"), r)
+		if err != nil {
+			if errors.Is(err, scrobbles.ErrInvalidInterval) {
+				ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			} else {
+				log.Printf("Error counting scrobbles per period: %v\n", err)
+				ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to count scrobbles"})
+			}
+			return
+		}
+		ctx.JSON(counts)
+	})
+
+	// Scrobble archive analytics - hour of day by weekday heatmap
+	app.Get("/api/history/heatmap", generalLimiter.Handler(), func(ctx iris.Context) {
+		r, err := scrobbles.ParseRange(ctx.URLParam("from"), ctx.URLParam("to"), ctx.URLParam("tz"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		heatmap, err := scrobbleArchive.Heatmap(r)
+		if err != nil {
+			log.Printf("Error building heatmap: %v\n", err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to build heatmap"})
+			return
+		}
+		ctx.JSON(heatmap)
+	})
+
+	// Scrobble archive analytics - current and longest daily listening streaks
+	app.Get("/api/history/streaks", generalLimiter.Handler(), func(ctx iris.Context) {
+		r, err := scrobbles.ParseRange(ctx.URLParam("from"), ctx.URLParam("to"), ctx.URLParam("tz"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		streaks, err := scrobbleArchive.Streaks(r)
+		if err != nil {
+			log.Printf("Error computing streaks: %v\n", err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to compute streaks"})
+			return
+		}
+		ctx.JSON(streaks)
+	})
+
+	// Scrobble archive analytics - artists first listened to within the range
+	app.Get("/api/history/first-listens", generalLimiter.Handler(), func(ctx iris.Context) {
+		r, err := scrobbles.ParseRange(ctx.URLParam("from"), ctx.URLParam("to"), ctx.URLParam("tz"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		limit := ctx.URLParamIntDefault("limit", 50)
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		listens, err := scrobbleArchive.FirstListens(r, limit)
+		if err != nil {
+			log.Printf("Error fetching first listens: %v\n", err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to fetch first listens"})
+			return
+		}
+		ctx.JSON(listens)
+	})
+
+	// Scrobble archive analytics - total scrobbles and distinct artists, tracks and albums
+	app.Get("/api/history/counts", generalLimiter.Handler(), func(ctx iris.Context) {
+		r, err := scrobbles.ParseRange(ctx.URLParam("from"), ctx.URLParam("to"), ctx.URLParam("tz"))
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+
+		counts, err := scrobbleArchive.Counts(r)
+		if err != nil {
+			log.Printf("Error counting scrobbles: %v\n", err)
+			ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"error": "Failed to count scrobbles"})
+			return
+		}
+		ctx.JSON(counts)
+	})
+
+	// Last.fm endpoint - Download the full scrobble history, streamed page by page
+	exportScrobbles := func(format string) iris.Handler {
+		return func(ctx iris.Context) {
+			r, err := scrobbles.ParseRange(ctx.URLParam("from"), ctx.URLParam("to"), ctx.URLParam("tz"))
+			if err != nil {
+				ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+				return
+			}
+
+			filename := "scrobbles-" + cfg.LastFMUsername
+			if !r.From.IsZero() {
+				filename += "-from-" + r.From.Format(time.DateOnly)
+			}
+			if !r.To.IsZero() {
+				filename += "-to-" + r.To.Format(time.DateOnly)
+			}
+			ctx.ContentType(export.Formats[format])
+			ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+			ctx.Header("Cache-Control", "no-store")
+
+			written, err := scrobbleExporter.Export(ctx.Request().Context(), ctx.ResponseWriter(), format, r.From, r.To)
+			if err == nil {
+				return
+			}
+			if written > 0 {
+				// Headers are gone, all that's left is to cut the download short
+				log.Printf("Scrobble export failed after %d rows: %v\n", written, err)
+				return
+			}
+
+			ctx.ResponseWriter().Header().Del("Content-Disposition")
+			if errors.Is(err, export.ErrBusy) {
+				ctx.Header("Retry-After", "60")
+				ctx.StopWithJSON(iris.StatusServiceUnavailable, iris.Map{"error": err.Error()})
+				return
+			}
+			log.Printf("Error exporting scrobbles: %v\n", err)
+			ctx.StopWithJSON(lastfmErrorStatus(err), iris.Map{"error": "Failed to export scrobbles"})
+		}
+	}
+	app.Get("/api/export/scrobbles.csv", generalLimiter.Handler(), exportScrobbles("csv"))
+	app.Get("/api/export/scrobbles.ndjson", generalLimiter.Handler(), exportScrobbles("ndjson"))
+
+	// Push webhook for the content repository, triggers an immediate sync
+	app.Post("/api/content/webhook", func(ctx iris.Context) {
+		if !contentSyncer.Enabled() || cfg.ContentWebhookSecret == "" {
+			ctx.StopWithStatus(iris.StatusNotFound)
+			return
+		}
+
+		body, err := ctx.GetBody()
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": "Failed to read body"})
+			return
+		}
+
+		if !contentSyncer.VerifyWebhook(body, ctx.GetHeader("X-Hub-Signature-256")) {
+			ctx.StopWithJSON(iris.StatusUnauthorized, iris.Map{"error": "Invalid signature"})
+			return
+		}
+
+		contentSyncer.Trigger()
+		ctx.StatusCode(iris.StatusAccepted)
+		ctx.JSON(iris.Map{"status": "sync scheduled"})
+	})
+
 	addr := ":" + cfg.Port
 	log.Printf("Starting server on %s\n", addr)
-	err := app.Run(iris.Addr(addr))
+	err = app.Run(iris.Addr(addr))
 	if err != nil {
 		log.Printf("Failed to start server: %v\n", err)
 		return
 	}
 }
+
+// writeRawJSON encodes v without overriding a Content-Type set earlier, which ctx.JSON would do
+func writeRawJSON(ctx iris.Context, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode response: %v\n", err)
+		ctx.StopWithStatus(iris.StatusInternalServerError)
+		return
+	}
+
+	if _, err := ctx.Write(data); err != nil {
+		log.Printf("Failed to send response: %v\n", err)
+	}
+}
+
+// setCacheHeaders tells browsers and CDNs how long a cached Last.fm response stays fresh. Age
+// already counts against max-age downstream, so max-age is the entry's full lifetime.
+func setCacheHeaders(ctx iris.Context, status *lastfm.CacheStatus) {
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-if-error=%d",
+		int(status.MaxAge.Seconds()), int(status.StaleIfError.Seconds())))
+	ctx.Header("Age", strconv.Itoa(int(status.Age.Seconds())))
+}
+
+// cardTheme reads the theme and colour override query parameters of a card request
+func cardTheme(ctx iris.Context) (cards.Theme, error) {
+	overrides := make(map[string]string)
+	for _, param := range cards.ColorParams {
+		overrides[param] = ctx.URLParam(param)
+	}
+	return cards.ParseTheme(ctx.URLParam("theme"), overrides)
+}
+
+// writeCard sends an SVG card. GitHub's camo proxy caches images by Cache-Control, so cards
+// carry an ETag and explicit lifetimes; a maxAge of 0 makes camo revalidate on every view.
+// Errors are rendered into the card with a 200 since camo shows a broken image otherwise.
+func writeCard(ctx iris.Context, svg []byte, maxAge time.Duration) {
+	sum := sha256.Sum256(svg)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	if maxAge > 0 {
+		seconds := int(maxAge.Seconds())
+		ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, s-maxage=%d, stale-while-revalidate=86400", seconds, seconds))
+	} else {
+		ctx.Header("Cache-Control", "public, max-age=0, no-cache")
+	}
+	ctx.Header("ETag", etag)
+	ctx.Header("Content-Type", "image/svg+xml; charset=utf-8")
+	// Keeps the SVG from running anything if opened directly
+	ctx.Header("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+
+	if ctx.GetHeader("If-None-Match") == etag {
+		ctx.StatusCode(iris.StatusNotModified)
+		return
+	}
+
+	if _, err := ctx.Write(svg); err != nil {
+		log.Printf("Failed to send card: %v\n", err)
+	}
+}
+
+// chartPeriod reads the period of a top-* request, or its from/to range when either is given
+// (the range's From is zero otherwise). Invalid values are answered with a 400 and ok is false.
+func chartPeriod(ctx iris.Context) (string, scrobbles.Range, bool) {
+	period := ctx.URLParamDefault("period", "7day") // weekly, monthly, yearly, alltime
+	if !lastfm.ValidPeriod(period) {
+		ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{
+			"error":        fmt.Sprintf("Invalid period %q", period),
+			"validPeriods": lastfm.Periods,
+		})
+		return "", scrobbles.Range{}, false
+	}
+
+	from, to := ctx.URLParam("from"), ctx.URLParam("to")
+	if from == "" && to == "" {
+		return period, scrobbles.Range{}, true
+	}
+	if from == "" {
+		ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": "from is required when to is given"})
+		return "", scrobbles.Range{}, false
+	}
+
+	r, err := scrobbles.ParseRange(from, to, "")
+	if err == nil {
+		if r.To.IsZero() {
+			r.To = time.Now()
+		}
+		err = lastfm.ValidateRange(r.From, r.To)
+	}
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+		return "", scrobbles.Range{}, false
+	}
+	return period, r, true
+}
+
+// lastfmErrorStatus maps Last.fm client errors to the status code returned to our callers
+func lastfmErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, lastfm.ErrNotFound):
+		return iris.StatusNotFound
+	case errors.Is(err, lastfm.ErrRateLimited), errors.Is(err, lastfm.ErrUnavailable):
+		return iris.StatusServiceUnavailable
+	case errors.Is(err, lastfm.ErrUnsupported):
+		return iris.StatusNotImplemented
+	default:
+		return iris.StatusInternalServerError
+	}
+}
@@ -0,0 +1,350 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/webmention"
+
+	"github.com/gomarkdown/markdown"
+)
+
+const (
+	ContentType = "application/activity+json"
+
+	publicCollection = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+var (
+	ErrDisabled     = errors.New("activitypub is disabled (set PUBLIC_URL)")
+	ErrUnknownUser  = errors.New("unknown user")
+	ErrBadSignature = errors.New("invalid http signature")
+	ErrBadActivity  = errors.New("invalid activity")
+)
+
+var activityContext = []string{
+	"https://www.w3.org/ns/activitystreams",
+	"https://w3id.org/security/v1",
+}
+
+type Service struct {
+	config *config.Config
+	db     *sql.DB
+	client *http.Client
+	key    *rsa.PrivateKey
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Actor struct {
+	Context           []string   `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	PublicKey         *PublicKey `json:"publicKey,omitempty"`
+	Endpoints         *struct {
+		SharedInbox string `json:"sharedInbox,omitempty"`
+	} `json:"endpoints,omitempty"`
+}
+
+type Article struct {
+	Context      []string `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	Name         string   `json:"name"`
+	Summary      string   `json:"summary,omitempty"`
+	Content      string   `json:"content"`
+	MediaType    string   `json:"mediaType"`
+	URL          string   `json:"url,omitempty"`
+	Published    string   `json:"published"`
+	To           []string `json:"to"`
+	Cc           []string `json:"cc"`
+}
+
+type Activity struct {
+	Context   []string `json:"@context,omitempty"`
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Actor     string   `json:"actor"`
+	Object    any      `json:"object"`
+	Published string   `json:"published,omitempty"`
+	To        []string `json:"to,omitempty"`
+	Cc        []string `json:"cc,omitempty"`
+}
+
+type OrderedCollection struct {
+	Context      []string `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	TotalItems   int      `json:"totalItems"`
+	OrderedItems any      `json:"orderedItems"`
+}
+
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// NewService loads (or creates on first run) the actor's signing key. Remote fetches and
+// deliveries go through client, which defaults to webmention.NewSafeClient when nil since key
+// and actor URLs come from whoever posts to the inbox.
+func NewService(cfg *config.Config, db *sql.DB, client *http.Client) (*Service, error) {
+	if client == nil {
+		client = webmention.NewSafeClient()
+	}
+
+	s := &Service{
+		config: cfg,
+		db:     db,
+		client: client,
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS activitypub_keys (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		private_key TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS activitypub_followers (
+		actor TEXT PRIMARY KEY,
+		inbox TEXT NOT NULL,
+		shared_inbox TEXT,
+		follow_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create activitypub tables: %w", err)
+	}
+
+	key, err := s.loadKey()
+	if err != nil {
+		return nil, err
+	}
+	s.key = key
+
+	return s, nil
+}
+
+func (s *Service) loadKey() (*rsa.PrivateKey, error) {
+	var encoded string
+	err := s.db.QueryRow("SELECT private_key FROM activitypub_keys WHERE id = 1").Scan(&encoded)
+	if err == nil {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, fmt.Errorf("stored activitypub key is not valid PEM")
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load activitypub key: %w", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate activitypub key: %w", err)
+	}
+
+	encoded = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	if _, err := s.db.Exec("INSERT INTO activitypub_keys (id, private_key) VALUES (1, ?)", encoded); err != nil {
+		return nil, fmt.Errorf("failed to store activitypub key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *Service) Enabled() bool {
+	return s.config.PublicURL != ""
+}
+
+func (s *Service) domain() string {
+	u, err := url.Parse(s.config.PublicURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (s *Service) ActorURL() string {
+	return s.config.PublicURL + "/api/activitypub/actor"
+}
+
+func (s *Service) inboxURL() string     { return s.config.PublicURL + "/api/activitypub/inbox" }
+func (s *Service) outboxURL() string    { return s.config.PublicURL + "/api/activitypub/outbox" }
+func (s *Service) followersURL() string { return s.config.PublicURL + "/api/activitypub/followers" }
+func (s *Service) keyID() string        { return s.ActorURL() + "#main-key" }
+
+func (s *Service) articleURL(id int) string {
+	return fmt.Sprintf("%s/api/activitypub/posts/%d", s.config.PublicURL, id)
+}
+
+// WebFinger resolves acct:user@domain to the actor document
+func (s *Service) WebFinger(resource string) (*WebFinger, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	account := s.config.ActivityPubUsername + "@" + s.domain()
+	if !strings.EqualFold(strings.TrimPrefix(resource, "acct:"), account) && resource != s.ActorURL() {
+		return nil, ErrUnknownUser
+	}
+
+	return &WebFinger{
+		Subject: "acct:" + account,
+		Aliases: []string{s.ActorURL()},
+		Links: []WebFingerLink{
+			{Rel: "self", Type: ContentType, Href: s.ActorURL()},
+		},
+	}, nil
+}
+
+func (s *Service) Actor() (*Actor, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	actor := &Actor{
+		Context:           activityContext,
+		ID:                s.ActorURL(),
+		Type:              "Person",
+		PreferredUsername: s.config.ActivityPubUsername,
+		Name:              s.domain(),
+		Summary:           "Blog posts from " + s.domain(),
+		URL:               s.config.SiteURL,
+		Inbox:             s.inboxURL(),
+		Outbox:            s.outboxURL(),
+		Followers:         s.followersURL(),
+		PublicKey: &PublicKey{
+			ID:           s.keyID(),
+			Owner:        s.ActorURL(),
+			PublicKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})),
+		},
+	}
+	return actor, nil
+}
+
+// Article converts a blog post to its ActivityStreams representation
+func (s *Service) Article(post *blog.Post) *Article {
+	article := &Article{
+		ID:           s.articleURL(post.ID),
+		Type:         "Article",
+		AttributedTo: s.ActorURL(),
+		Name:         post.Title,
+		Summary:      post.Description,
+		Content:      string(markdown.ToHTML([]byte(post.Markdown), nil, nil)),
+		MediaType:    "text/html",
+		Published:    post.CreatedAt.UTC().Format(time.RFC3339),
+		To:           []string{publicCollection},
+		Cc:           []string{s.followersURL()},
+	}
+	if s.config.SiteURL != "" {
+		article.URL = blog.PostURL(s.config.SiteURL, post.ID)
+	}
+	return article
+}
+
+func (s *Service) createActivity(post *blog.Post) *Activity {
+	article := s.Article(post)
+	return &Activity{
+		ID:        article.ID + "/activity",
+		Type:      "Create",
+		Actor:     s.ActorURL(),
+		Object:    article,
+		Published: article.Published,
+		To:        article.To,
+		Cc:        article.Cc,
+	}
+}
+
+// Outbox lists every blog post as a Create activity, newest first
+func (s *Service) Outbox() (*OrderedCollection, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	posts, err := blog.GetAllPosts()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Activity, 0, len(posts))
+	for i := range posts {
+		items = append(items, s.createActivity(&posts[i]))
+	}
+
+	return &OrderedCollection{
+		Context:      activityContext,
+		ID:           s.outboxURL(),
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	}, nil
+}
+
+// Followers lists the actor IDs following the blog
+func (s *Service) Followers() (*OrderedCollection, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	rows, err := s.db.Query("SELECT actor FROM activitypub_followers ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actors := make([]string, 0)
+	for rows.Next() {
+		var actor string
+		if err := rows.Scan(&actor); err != nil {
+			return nil, err
+		}
+		actors = append(actors, actor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &OrderedCollection{
+		Context:      activityContext,
+		ID:           s.followersURL(),
+		Type:         "OrderedCollection",
+		TotalItems:   len(actors),
+		OrderedItems: actors,
+	}, nil
+}
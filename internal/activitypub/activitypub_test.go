@@ -0,0 +1,368 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+const blogURL = "https://blog.example"
+
+// delivery is a request received by a fake instance's inbox
+type delivery struct {
+	path string
+	req  *http.Request
+	body []byte
+}
+
+// fakeInstance is a remote ActivityPub server with one actor, alice, and an inbox that
+// records what it receives. extra serves additional documents by path.
+type fakeInstance struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	deliveries chan delivery
+	extra      map[string]any
+}
+
+func newFakeInstance(t *testing.T) *fakeInstance {
+	t.Helper()
+
+	f := &fakeInstance{
+		key:        newKey(t),
+		deliveries: make(chan delivery, 10),
+		extra:      make(map[string]any),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := io.ReadAll(r.Body)
+			f.deliveries <- delivery{path: r.URL.Path, req: r, body: body}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var doc any
+		switch r.URL.Path {
+		case "/users/alice":
+			doc = f.actor()
+		default:
+			var ok bool
+			if doc, ok = f.extra[r.URL.Path]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeInstance) actorID() string { return f.server.URL + "/users/alice" }
+func (f *fakeInstance) keyID() string   { return f.actorID() + "#main-key" }
+
+func (f *fakeInstance) actor() *Actor {
+	actor := &Actor{
+		ID:                f.actorID(),
+		Type:              "Person",
+		PreferredUsername: "alice",
+		Inbox:             f.actorID() + "/inbox",
+		PublicKey:         &PublicKey{ID: f.keyID(), Owner: f.actorID(), PublicKeyPem: encodeKey(f.key)},
+	}
+	actor.Endpoints = &struct {
+		SharedInbox string `json:"sharedInbox,omitempty"`
+	}{SharedInbox: f.server.URL + "/inbox"}
+	return actor
+}
+
+func (f *fakeInstance) next(t *testing.T) delivery {
+	t.Helper()
+	select {
+	case d := <-f.deliveries:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+		return delivery{}
+	}
+}
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return key
+}
+
+func encodeKey(key *rsa.PrivateKey) string {
+	pub, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+}
+
+// newTestService returns a service for blogURL using client, which tests point at httptest
+// servers on loopback
+func newTestService(t *testing.T, client *http.Client) *Service {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{PublicURL: blogURL, SiteURL: blogURL, ActivityPubUsername: "blog"}
+	s, err := NewService(cfg, db, client)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+// inboxRequest builds a POST to the blog's inbox signed with key under keyID
+func inboxRequest(t *testing.T, body []byte, key *rsa.PrivateKey, keyID string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest("POST", blogURL+"/api/activitypub/inbox", bytes.NewReader(body))
+	if err := signRequest(req, body, key, keyID); err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	return req
+}
+
+func followBody(actor string) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"id":     actor + "#follows/1",
+		"type":   "Follow",
+		"actor":  actor,
+		"object": blogURL + "/api/activitypub/actor",
+	})
+	return body
+}
+
+func followerCount(t *testing.T, s *Service) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM activitypub_followers").Scan(&n); err != nil {
+		t.Fatalf("counting followers: %v", err)
+	}
+	return n
+}
+
+// checkSignedBy verifies a request received from the blog against its actor key
+func checkSignedBy(t *testing.T, s *Service, d delivery) {
+	t.Helper()
+
+	params := parseSignature(d.req.Header.Get("Signature"))
+	if params["keyId"] != s.keyID() {
+		t.Errorf("keyId = %q, want %q", params["keyId"], s.keyID())
+	}
+	sum := sha256.Sum256(d.body)
+	if d.req.Header.Get("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Error("digest does not match the body")
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(params["signature"])
+	hashed := sha256.Sum256([]byte(signingString(d.req, strings.Fields(params["headers"]))))
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, hashed[:], raw); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestFollowAndUndo(t *testing.T) {
+	remote := newFakeInstance(t)
+	s := newTestService(t, remote.server.Client())
+
+	body := followBody(remote.actorID())
+	if err := s.HandleInbox(inboxRequest(t, body, remote.key, remote.keyID()), body); err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	if n := followerCount(t, s); n != 1 {
+		t.Fatalf("%d followers after Follow, want 1", n)
+	}
+
+	accept := remote.next(t)
+	if accept.path != "/users/alice/inbox" {
+		t.Errorf("Accept delivered to %s, want alice's inbox", accept.path)
+	}
+	var activity Activity
+	if err := json.Unmarshal(accept.body, &activity); err != nil || activity.Type != "Accept" {
+		t.Errorf("unexpected Accept %s: %v", accept.body, err)
+	}
+	checkSignedBy(t, s, accept)
+
+	undo, _ := json.Marshal(map[string]any{
+		"id":     remote.actorID() + "#undo/1",
+		"type":   "Undo",
+		"actor":  remote.actorID(),
+		"object": json.RawMessage(body),
+	})
+	if err := s.HandleInbox(inboxRequest(t, undo, remote.key, remote.keyID()), undo); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if n := followerCount(t, s); n != 0 {
+		t.Errorf("%d followers after Undo, want 0", n)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	remote := newFakeInstance(t)
+	other := newFakeInstance(t)
+	attacker := newKey(t)
+
+	// A key document on alice's origin claiming alice as owner, which alice doesn't publish
+	remote.extra["/keys/forged"] = PublicKey{
+		ID:           remote.server.URL + "/keys/forged",
+		Owner:        remote.actorID(),
+		PublicKeyPem: encodeKey(attacker),
+	}
+	// The same claim hosted on another origin
+	other.extra["/keys/forged"] = PublicKey{
+		ID:           other.server.URL + "/keys/forged",
+		Owner:        remote.actorID(),
+		PublicKeyPem: encodeKey(attacker),
+	}
+
+	body := followBody(remote.actorID())
+	tests := []struct {
+		name string
+		req  func() *http.Request
+	}{
+		{"unsigned", func() *http.Request {
+			return httptest.NewRequest("POST", blogURL+"/api/activitypub/inbox", bytes.NewReader(body))
+		}},
+		{"signed with another key", func() *http.Request {
+			return inboxRequest(t, body, attacker, remote.keyID())
+		}},
+		{"key document with a forged owner", func() *http.Request {
+			return inboxRequest(t, body, attacker, remote.server.URL+"/keys/forged")
+		}},
+		{"key on another origin than its owner", func() *http.Request {
+			return inboxRequest(t, body, attacker, other.server.URL+"/keys/forged")
+		}},
+		{"tampered body", func() *http.Request {
+			return inboxRequest(t, followBody(remote.actorID()+"x"), remote.key, remote.keyID())
+		}},
+		{"date not signed", func() *http.Request {
+			req := inboxRequest(t, body, remote.key, remote.keyID())
+			sig := req.Header.Get("Signature")
+			req.Header.Set("Signature", strings.Replace(sig, `headers="(request-target) host date digest"`, `headers="(request-target) host digest"`, 1))
+			return req
+		}},
+		{"stale date", func() *http.Request {
+			req := httptest.NewRequest("POST", blogURL+"/api/activitypub/inbox", bytes.NewReader(body))
+			signAt(t, req, body, remote.key, remote.keyID(), time.Now().Add(-2*maxClockSkew))
+			return req
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, remote.server.Client())
+			err := s.HandleInbox(tt.req(), body)
+			if !errors.Is(err, ErrBadSignature) {
+				t.Errorf("error = %v, want ErrBadSignature", err)
+			}
+			if n := followerCount(t, s); n != 0 {
+				t.Errorf("%d followers stored, want 0", n)
+			}
+		})
+	}
+}
+
+// signAt signs like signRequest but with a chosen Date
+func signAt(t *testing.T, req *http.Request, body []byte, key *rsa.PrivateKey, keyID string, date time.Time) {
+	t.Helper()
+	sum := sha256.Sum256(body)
+	req.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+
+	headers := []string{"(request-target)", "host", "date", "digest"}
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	req.Header.Set("Signature", `keyId="`+keyID+`",algorithm="rsa-sha256",headers="`+
+		strings.Join(headers, " ")+`",signature="`+base64.StdEncoding.EncodeToString(sig)+`"`)
+}
+
+func TestDeliverPost(t *testing.T) {
+	remote := newFakeInstance(t)
+	s := newTestService(t, remote.server.Client())
+
+	// Two followers behind alice's shared inbox and one with only a personal inbox
+	_, err := s.db.Exec(`INSERT INTO activitypub_followers (actor, inbox, shared_inbox) VALUES
+		(?, ?, ?), (?, ?, ?), (?, ?, '')`,
+		remote.actorID(), remote.actorID()+"/inbox", remote.server.URL+"/inbox",
+		remote.server.URL+"/users/bob", remote.server.URL+"/users/bob/inbox", remote.server.URL+"/inbox",
+		remote.server.URL+"/users/carol", remote.server.URL+"/users/carol/inbox",
+	)
+	if err != nil {
+		t.Fatalf("adding followers: %v", err)
+	}
+
+	post := &blog.Post{
+		Blog:     blog.Blog{ID: 7, Title: "Hello", CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Markdown: "Some *text*",
+	}
+	s.DeliverPost(post)
+
+	paths := map[string]bool{}
+	for range 2 {
+		d := remote.next(t)
+		paths[d.path] = true
+		checkSignedBy(t, s, d)
+
+		var activity struct {
+			Type   string  `json:"type"`
+			Object Article `json:"object"`
+		}
+		if err := json.Unmarshal(d.body, &activity); err != nil {
+			t.Fatalf("decoding delivery: %v", err)
+		}
+		if activity.Type != "Create" || activity.Object.ID != blogURL+"/api/activitypub/posts/7" ||
+			activity.Object.URL != blogURL+"/blog/7" || !strings.Contains(activity.Object.Content, "<em>text</em>") {
+			t.Errorf("unexpected activity: %s", d.body)
+		}
+	}
+	if !paths["/inbox"] || !paths["/users/carol/inbox"] {
+		t.Errorf("delivered to %v, want the shared inbox once and carol's inbox", paths)
+	}
+	select {
+	case d := <-remote.deliveries:
+		t.Errorf("unexpected extra delivery to %s", d.path)
+	default:
+	}
+}
+
+func TestDefaultClientRefusesLoopback(t *testing.T) {
+	remote := newFakeInstance(t)
+	s := newTestService(t, nil)
+
+	body := followBody(remote.actorID())
+	err := s.HandleInbox(inboxRequest(t, body, remote.key, remote.keyID()), body)
+	if !errors.Is(err, ErrBadSignature) {
+		t.Errorf("error = %v, want the key fetch to loopback refused", err)
+	}
+}
@@ -0,0 +1,195 @@
+package activitypub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"tringldev-server/internal/blog"
+)
+
+type incomingActivity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// HandleInbox verifies and processes an activity POSTed to the inbox. Only Follow and
+// Undo(Follow) do anything; other activity types are accepted and ignored.
+func (s *Service) HandleInbox(req *http.Request, body []byte) error {
+	if !s.Enabled() {
+		return ErrDisabled
+	}
+
+	var activity incomingActivity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadActivity, err)
+	}
+	if activity.Actor == "" {
+		return fmt.Errorf("%w: missing actor", ErrBadActivity)
+	}
+
+	owner, err := s.verify(req, body)
+	if err != nil {
+		return err
+	}
+	if owner != activity.Actor {
+		return fmt.Errorf("%w: signed by %s on behalf of %s", ErrBadSignature, owner, activity.Actor)
+	}
+
+	switch activity.Type {
+	case "Follow":
+		return s.handleFollow(&activity, body)
+	case "Undo":
+		return s.handleUndo(&activity)
+	default:
+		return nil
+	}
+}
+
+func (s *Service) handleFollow(activity *incomingActivity, raw []byte) error {
+	var object string
+	if err := json.Unmarshal(activity.Object, &object); err != nil || object != s.ActorURL() {
+		return fmt.Errorf("%w: follow object is not this actor", ErrBadActivity)
+	}
+
+	doc, err := s.fetch(activity.Actor)
+	if err != nil {
+		return fmt.Errorf("failed to fetch follower: %w", err)
+	}
+
+	var follower Actor
+	if err := json.Unmarshal(doc, &follower); err != nil {
+		return fmt.Errorf("failed to decode follower: %w", err)
+	}
+	if follower.ID != activity.Actor || follower.Inbox == "" {
+		return fmt.Errorf("%w: follower actor document is incomplete", ErrBadActivity)
+	}
+
+	sharedInbox := ""
+	if follower.Endpoints != nil {
+		sharedInbox = follower.Endpoints.SharedInbox
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO activitypub_followers (actor, inbox, shared_inbox, follow_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT(actor) DO UPDATE SET inbox = excluded.inbox, shared_inbox = excluded.shared_inbox, follow_id = excluded.follow_id`,
+		follower.ID, follower.Inbox, sharedInbox, activity.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store follower: %w", err)
+	}
+
+	accept := &Activity{
+		Context: activityContext,
+		ID:      fmt.Sprintf("%s#accepts/%s", s.ActorURL(), activity.ID),
+		Type:    "Accept",
+		Actor:   s.ActorURL(),
+		Object:  json.RawMessage(raw),
+	}
+
+	go func() {
+		if err := s.deliver(follower.Inbox, accept); err != nil {
+			log.Printf("Failed to send Accept to %s: %v\n", follower.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Service) handleUndo(activity *incomingActivity) error {
+	var inner incomingActivity
+	if err := json.Unmarshal(activity.Object, &inner); err != nil {
+		// Some servers only reference the undone activity by id
+		var id string
+		if err := json.Unmarshal(activity.Object, &id); err != nil {
+			return fmt.Errorf("%w: undo object is neither an activity nor an id", ErrBadActivity)
+		}
+		_, err := s.db.Exec("DELETE FROM activitypub_followers WHERE actor = ? AND follow_id = ?", activity.Actor, id)
+		return err
+	}
+
+	if inner.Type != "Follow" {
+		return nil
+	}
+	if inner.Actor != "" && inner.Actor != activity.Actor {
+		return fmt.Errorf("%w: cannot undo another actor's follow", ErrBadActivity)
+	}
+
+	_, err := s.db.Exec("DELETE FROM activitypub_followers WHERE actor = ?", activity.Actor)
+	return err
+}
+
+// DeliverPost sends a Create activity for a newly published post to every follower,
+// using shared inboxes where available so each server only receives it once
+func (s *Service) DeliverPost(post *blog.Post) {
+	if !s.Enabled() {
+		return
+	}
+
+	inboxes, err := s.followerInboxes()
+	if err != nil {
+		log.Printf("Failed to load followers: %v\n", err)
+		return
+	}
+
+	activity := s.createActivity(post)
+	activity.Context = activityContext
+
+	for _, inbox := range inboxes {
+		if err := s.deliver(inbox, activity); err != nil {
+			log.Printf("Failed to deliver post %d to %s: %v\n", post.ID, inbox, err)
+		}
+	}
+}
+
+func (s *Service) followerInboxes() ([]string, error) {
+	rows, err := s.db.Query("SELECT DISTINCT COALESCE(NULLIF(shared_inbox, ''), inbox) FROM activitypub_followers")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inboxes := make([]string, 0)
+	for rows.Next() {
+		var inbox string
+		if err := rows.Scan(&inbox); err != nil {
+			return nil, err
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	return inboxes, rows.Err()
+}
+
+func (s *Service) deliver(inbox string, activity *Activity) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to encode activity: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", inbox, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if err := s.sign(req, body); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver activity: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("inbox returned %d: %s", resp.StatusCode, string(msg))
+	}
+
+	return nil
+}
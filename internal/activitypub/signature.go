@@ -0,0 +1,247 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// How far a signed Date may be from now. Remote clocks drift a little, but a wide window
+// lets a captured request be replayed long after it was sent.
+const maxClockSkew = time.Hour
+
+// sign adds draft-cavage HTTP signature headers to an outgoing request. body is nil for GETs.
+func (s *Service) sign(req *http.Request, body []byte) error {
+	return signRequest(req, body, s.key, s.keyID())
+}
+
+func signRequest(req *http.Request, body []byte, key *rsa.PrivateKey, keyID string) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.URL.Host)
+
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		headers = append(headers, "digest")
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig),
+	))
+	return nil
+}
+
+// verify checks the signature on an incoming request and returns the actor that owns the signing key
+func (s *Service) verify(req *http.Request, body []byte) (string, error) {
+	params := parseSignature(req.Header.Get("Signature"))
+	keyID, signature := params["keyId"], params["signature"]
+	if keyID == "" || signature == "" {
+		return "", fmt.Errorf("%w: missing signature", ErrBadSignature)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	if !contains(headers, "(request-target)") || !contains(headers, "digest") || !contains(headers, "date") {
+		return "", fmt.Errorf("%w: request-target, date and digest must be signed", ErrBadSignature)
+	}
+
+	sum := sha256.Sum256(body)
+	if req.Header.Get("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]) {
+		return "", fmt.Errorf("%w: digest mismatch", ErrBadSignature)
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("%w: bad date header", ErrBadSignature)
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", fmt.Errorf("%w: date outside allowed window", ErrBadSignature)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: signature is not base64", ErrBadSignature)
+	}
+
+	key, err := s.fetchKey(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	pub, err := parsePublicKey(key.PublicKeyPem)
+	if err != nil {
+		return "", err
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], raw); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	return key.Owner, nil
+}
+
+// fetchKey dereferences a keyId, which is usually an actor URL with a #main-key fragment. A
+// key document can name any owner, so the key is only trusted once the owner's own actor
+// document, on the same origin, publishes it under that id.
+func (s *Service) fetchKey(keyID string) (*PublicKey, error) {
+	doc, err := s.fetch(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch key: %v", ErrBadSignature, err)
+	}
+
+	var wrapper struct {
+		PublicKey
+		Key *PublicKey `json:"publicKey"`
+	}
+	if err := json.Unmarshal(doc, &wrapper); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode key: %v", ErrBadSignature, err)
+	}
+
+	key := &wrapper.PublicKey
+	if wrapper.Key != nil {
+		key = wrapper.Key
+	}
+	if key.PublicKeyPem == "" || key.Owner == "" {
+		return nil, fmt.Errorf("%w: key document has no public key", ErrBadSignature)
+	}
+
+	if !sameOrigin(keyID, key.Owner) {
+		return nil, fmt.Errorf("%w: key %s is not on its owner's origin", ErrBadSignature, keyID)
+	}
+
+	// The keyId usually is the owner's actor document, which then already vouches for the key
+	owner := wrapper.Key
+	if wrapper.ID != key.Owner || owner == nil {
+		actorDoc, err := s.fetch(key.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to fetch key owner: %v", ErrBadSignature, err)
+		}
+		var actor Actor
+		if err := json.Unmarshal(actorDoc, &actor); err != nil {
+			return nil, fmt.Errorf("%w: failed to decode key owner: %v", ErrBadSignature, err)
+		}
+		if actor.ID != key.Owner {
+			return nil, fmt.Errorf("%w: key owner document has id %s", ErrBadSignature, actor.ID)
+		}
+		owner = actor.PublicKey
+	}
+	if owner == nil || owner.ID != keyID || owner.PublicKeyPem != key.PublicKeyPem {
+		return nil, fmt.Errorf("%w: %s does not publish key %s", ErrBadSignature, key.Owner, keyID)
+	}
+
+	return key, nil
+}
+
+// sameOrigin reports whether two URLs share scheme and host
+func sameOrigin(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && ua.Host != "" &&
+		strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// fetch does a signed GET for an ActivityPub document, as servers in authorized fetch mode require
+func (s *Service) fetch(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType+`, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	if err := s.sign(req, nil); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", rawURL, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines = append(lines, "host: "+host)
+		default:
+			lines = append(lines, h+": "+req.Header.Get(h))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseSignature(header string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[key] = strings.Trim(val, `"`)
+		}
+	}
+	return params
+}
+
+func parsePublicKey(encoded string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("%w: public key is not valid PEM", ErrBadSignature)
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: only RSA keys are supported", ErrBadSignature)
+	}
+	return pub, nil
+}
+
+func contains(list []string, want string) bool {
+	for _, v := range list {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
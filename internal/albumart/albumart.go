@@ -0,0 +1,249 @@
+package albumart
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/config"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// PlaceholderID is served in place of art Last.fm doesn't have
+	PlaceholderID = "placeholder"
+
+	// Last.fm's grey star image, returned for albums without artwork
+	blankStarID = "2a96cbd8b46e442fc41c2b86b821562f"
+
+	upstreamURL = "https://lastfm.freetls.fastly.net/i/u/300x300/"
+
+	DefaultSize = 300
+
+	maxUpstreamBytes = 5 << 20
+	maxDimension     = 4096
+)
+
+// Sizes art can be requested at. Requests are rounded up to one of these so a handful of
+// files per album are cached, whatever sizes clients ask for.
+var Sizes = []int{64, 128, 174, 300}
+
+var (
+	ErrInvalidID = errors.New("invalid album art id")
+	ErrNotFound  = errors.New("album art not found")
+)
+
+var (
+	artHosts = map[string]bool{
+		"lastfm.freetls.fastly.net": true,
+		"lastfm-img2.akamaized.net": true,
+	}
+	artPath = regexp.MustCompile(`^/i/u/(?:[0-9a-z]+/)?([0-9a-f]{32})\.[a-z]+$`)
+	validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+type Image struct {
+	Data        []byte
+	ContentType string
+	// Whether this is generated in place of missing art, which callers shouldn't cache for long
+	Placeholder bool
+}
+
+// Service proxies Last.fm album art through /api/art/{id}, so visitors never talk to Last.fm,
+// resizing it and keeping the results in a size-capped disk cache
+type Service struct {
+	config   *config.Config
+	client   *http.Client
+	upstream string
+	cache    *diskCache
+	group    singleflight.Group
+}
+
+func NewService(cfg *config.Config) (*Service, error) {
+	cache, err := newDiskCache(cfg.ArtCacheDir, cfg.ArtCacheMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		config:   cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		upstream: upstreamURL,
+		cache:    cache,
+	}, nil
+}
+
+// Rewrite turns a Last.fm image URL into a link to the proxy. URLs it doesn't recognise are
+// returned unchanged.
+func (s *Service) Rewrite(artURL string) string {
+	id, ok := lastfmImageID(artURL)
+	if !ok {
+		return artURL
+	}
+	return s.config.PublicURL + "/api/art/" + id
+}
+
+func lastfmImageID(artURL string) (string, bool) {
+	u, err := url.Parse(artURL)
+	if err != nil || !artHosts[u.Host] {
+		return "", false
+	}
+
+	m := artPath.FindStringSubmatch(u.Path)
+	if m == nil {
+		return "", false
+	}
+	if m[1] == blankStarID {
+		return PlaceholderID, true
+	}
+	return m[1], true
+}
+
+// Load returns the art behind a URL produced by Rewrite, or behind a raw Last.fm URL
+func (s *Service) Load(ctx context.Context, artURL string, size int) (*Image, error) {
+	if id, ok := lastfmImageID(artURL); ok {
+		return s.Get(ctx, id, size)
+	}
+
+	u, err := url.Parse(artURL)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	id, ok := strings.CutPrefix(u.Path, "/api/art/")
+	if !ok {
+		return nil, ErrInvalidID
+	}
+	return s.Get(ctx, id, size)
+}
+
+// Get returns the art with the given Last.fm image id as a JPEG no larger than size.
+// Missing upstream art is reported as ErrNotFound.
+func (s *Service) Get(ctx context.Context, id string, size int) (*Image, error) {
+	size = snapSize(size)
+
+	if id == PlaceholderID {
+		return placeholder(size), nil
+	}
+	if !validID.MatchString(id) {
+		return nil, ErrInvalidID
+	}
+
+	key := id + "-" + strconv.Itoa(size)
+	if data, ok := s.cache.get(key); ok {
+		return &Image{Data: data, ContentType: "image/jpeg"}, nil
+	}
+
+	// The fetch is shared, so it mustn't be cut short by whichever request started it
+	result, err, _ := s.group.Do(key, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+		defer cancel()
+
+		data, err := s.fetch(fetchCtx, id, size)
+		if err != nil {
+			return nil, err
+		}
+		s.cache.put(key, data)
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{Data: result.([]byte), ContentType: "image/jpeg"}, nil
+}
+
+// fetch downloads, validates and resizes an image from Last.fm
+func (s *Service) fetch(ctx context.Context, id string, size int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.upstream+id+".png", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch album art: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("album art request failed with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read album art: %w", err)
+	}
+	if len(data) > maxUpstreamBytes {
+		return nil, fmt.Errorf("album art is larger than %d bytes", maxUpstreamBytes)
+	}
+
+	// Check the dimensions before decoding so a tiny file can't claim a huge canvas
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("album art is not a supported image: %w", err)
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return nil, fmt.Errorf("album art is %dx%d, larger than allowed", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode album art: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resize(src, size), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("failed to encode album art: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resize scales img so its longer side is size, never enlarging it. The result is drawn onto
+// an opaque canvas since JPEG has no transparency.
+func resize(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(h*size/w, 1)
+		} else {
+			w, h = max(w*size/h, 1), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// snapSize rounds a requested size up to the nearest supported one
+func snapSize(size int) int {
+	if size <= 0 {
+		return DefaultSize
+	}
+	for _, s := range Sizes {
+		if size <= s {
+			return s
+		}
+	}
+	return Sizes[len(Sizes)-1]
+}
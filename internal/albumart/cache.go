@@ -0,0 +1,170 @@
+package albumart
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheExt = ".jpg"
+
+type cacheItem struct {
+	key  string
+	size int64
+}
+
+// diskCache keeps encoded images on disk, evicting the least recently used once the total
+// size passes maxBytes. File modification times record use, so the order survives restarts.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+	total int64
+}
+
+func newDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create art cache dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read art cache dir: %w", err)
+	}
+
+	type existing struct {
+		cacheItem
+		usedAt time.Time
+	}
+	var files []existing
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, cacheExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, existing{
+			cacheItem: cacheItem{key: strings.TrimSuffix(name, cacheExt), size: info.Size()},
+			usedAt:    info.ModTime(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].usedAt.Before(files[j].usedAt) })
+
+	c := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+	for _, f := range files {
+		item := f.cacheItem
+		c.items[item.key] = c.order.PushFront(&item)
+		c.total += item.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key+cacheExt)
+}
+
+func (c *diskCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.remove(key)
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+func (c *diskCache) put(key string, data []byte) {
+	// Write then rename so readers never see a partial file
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		log.Printf("Failed to cache album art: %v\n", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Failed to cache album art: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Failed to cache album art: %v\n", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.total -= el.Value.(*cacheItem).size
+		c.order.Remove(el)
+	}
+	item := &cacheItem{key: key, size: int64(len(data))}
+	c.items[key] = c.order.PushFront(item)
+	c.total += item.size
+	c.evict()
+}
+
+func (c *diskCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.total -= el.Value.(*cacheItem).size
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// evict drops least recently used files until the cache fits. Callers hold c.mu.
+func (c *diskCache) evict() {
+	for c.total > c.maxBytes && c.order.Len() > 0 {
+		el := c.order.Back()
+		item := el.Value.(*cacheItem)
+
+		if err := os.Remove(c.path(item.key)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to evict album art %s: %v\n", item.key, err)
+		}
+		c.total -= item.size
+		c.order.Remove(el)
+		delete(c.items, item.key)
+	}
+}
@@ -0,0 +1,81 @@
+package albumart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sync"
+)
+
+var (
+	placeholderBackground = color.RGBA{R: 0x1e, G: 0x1e, B: 0x2e, A: 0xff}
+	placeholderRecord     = color.RGBA{R: 0x2a, G: 0x2a, B: 0x3a, A: 0xff}
+	placeholderGroove     = color.RGBA{R: 0x33, G: 0x33, B: 0x45, A: 0xff}
+	placeholderLabel      = color.RGBA{R: 0x58, G: 0xb9, B: 0xff, A: 0xff}
+)
+
+var (
+	placeholderMu    sync.Mutex
+	placeholderCache = make(map[int][]byte)
+)
+
+// placeholder draws a vinyl record to stand in for missing album art
+func placeholder(size int) *Image {
+	placeholderMu.Lock()
+	defer placeholderMu.Unlock()
+
+	data, ok := placeholderCache[size]
+	if !ok {
+		data = renderPlaceholder(size)
+		placeholderCache[size] = data
+	}
+	return &Image{Data: data, ContentType: "image/png", Placeholder: true}
+}
+
+func renderPlaceholder(size int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	center := float64(size) / 2
+
+	record := float64(size) * 0.42
+	label := float64(size) * 0.15
+	hole := float64(size) * 0.025
+	grooves := []float64{0.36, 0.30, 0.24}
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			d := math.Hypot(float64(x)+0.5-center, float64(y)+0.5-center)
+
+			c := placeholderBackground
+			c = blend(c, placeholderRecord, coverage(record, d))
+			for _, g := range grooves {
+				// Thin rings, one pixel wide whatever the size
+				ring := coverage(float64(size)*g+0.5, d) - coverage(float64(size)*g-0.5, d)
+				c = blend(c, placeholderGroove, ring)
+			}
+			c = blend(c, placeholderLabel, coverage(label, d))
+			c = blend(c, placeholderBackground, coverage(hole, d))
+
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	// Encoding an in-memory RGBA image can't fail
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// coverage is how much of the pixel at distance d from the centre lies inside radius r,
+// giving anti-aliased edges
+func coverage(r, d float64) float64 {
+	return math.Max(0, math.Min(1, r-d+0.5))
+}
+
+func blend(dst, src color.RGBA, alpha float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a)*(1-alpha) + float64(b)*alpha + 0.5)
+	}
+	return color.RGBA{R: mix(dst.R, src.R), G: mix(dst.G, src.G), B: mix(dst.B, src.B), A: 0xff}
+}
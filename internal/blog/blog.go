@@ -2,6 +2,7 @@ package blog
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -18,6 +19,11 @@ type Blog struct {
 type Post struct {
 	Blog
 	Markdown string // markdown
+	OGImage  string `json:"ogImage,omitempty"` // social preview image, filled in by the handler
+
+	// Last change to the post's source file when it is synced from git
+	CommitSHA    string `json:"commitSha,omitempty"`
+	CommitAuthor string `json:"commitAuthor,omitempty"`
 }
 
 type MarkdownDocument struct {
@@ -25,6 +31,10 @@ type MarkdownDocument struct {
 	Description string
 	Filepath    string
 	LastUpdated time.Time
+	Published   time.Time
+	Markdown    string
+	CommitSHA   string
+	Author      string
 }
 
 type BlogTable struct {
@@ -53,13 +63,61 @@ func InitDatabase() error {
 		markdown TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
-	_, err = DB.Exec(query)
+	if _, err = DB.Exec(query); err != nil {
+		return err
+	}
+
+	if err := migrateBlogs(); err != nil {
+		return err
+	}
+
+	return initWebmentions()
+}
+
+// migrateBlogs adds the columns introduced after the original schema to existing databases
+func migrateBlogs() error {
+	columns := []struct{ name, definition string }{
+		{"updated_at", "DATETIME"},
+		{"source_path", "TEXT"},
+		{"commit_sha", "TEXT"},
+		{"commit_author", "TEXT"},
+		{"archived", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	existing := make(map[string]bool)
+	rows, err := DB.Query("PRAGMA table_info(blogs)")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, c := range columns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE blogs ADD COLUMN %s %s", c.name, c.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", c.name, err)
+		}
+	}
 
-	return nil
+	_, err = DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS blogs_source_path ON blogs(source_path)")
+	return err
 }
 
 func GetListOfBlogInfo() ([]Blog, error) {
-	rows, err := DB.Query("SELECT id, title, description, created_at FROM blogs ORDER BY created_at DESC")
+	rows, err := DB.Query("SELECT id, title, description, created_at, updated_at FROM blogs WHERE archived = 0 ORDER BY created_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -68,21 +126,101 @@ func GetListOfBlogInfo() ([]Blog, error) {
 	var blogs []Blog
 	for rows.Next() {
 		var b Blog
-		if err := rows.Scan(&b.ID, &b.Title, &b.Description, &b.CreatedAt); err != nil {
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&b.ID, &b.Title, &b.Description, &b.CreatedAt, &updatedAt); err != nil {
 			return nil, err
 		}
+		b.UpdatedAt = updatedOrCreated(updatedAt, b.CreatedAt)
 		blogs = append(blogs, b)
 	}
 	return blogs, nil
 }
 
 func GetBlogByID(id int) (*Post, error) {
-	row := DB.QueryRow("SELECT id, title, description, markdown, created_at FROM blogs WHERE id = ?", id)
+	row := DB.QueryRow(`
+		SELECT id, title, description, markdown, created_at, updated_at, commit_sha, commit_author
+		FROM blogs WHERE id = ? AND archived = 0`, id)
 
 	var p Post
-	err := row.Scan(&p.ID, &p.Title, &p.Description, &p.Markdown, &p.CreatedAt)
+	var updatedAt sql.NullTime
+	var commitSHA, commitAuthor sql.NullString
+	err := row.Scan(&p.ID, &p.Title, &p.Description, &p.Markdown, &p.CreatedAt, &updatedAt, &commitSHA, &commitAuthor)
 	if err != nil {
 		return nil, err
 	}
+	p.UpdatedAt = updatedOrCreated(updatedAt, p.CreatedAt)
+	p.CommitSHA = commitSHA.String
+	p.CommitAuthor = commitAuthor.String
 	return &p, nil
 }
+
+// GetAllPosts returns every post including its markdown, newest first
+func GetAllPosts() ([]Post, error) {
+	rows, err := DB.Query("SELECT id, title, description, markdown, created_at, updated_at FROM blogs WHERE archived = 0 ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]Post, 0)
+	for rows.Next() {
+		var p Post
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.Markdown, &p.CreatedAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		p.UpdatedAt = updatedOrCreated(updatedAt, p.CreatedAt)
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// PostURL is the public permalink of a post on the frontend
+func PostURL(siteURL string, id int) string {
+	return fmt.Sprintf("%s/blog/%d", siteURL, id)
+}
+
+// PublishHook is called with every newly published post
+type PublishHook func(post *Post)
+
+var publishHooks []PublishHook
+
+// OnPublish registers a hook to run after CreatePost succeeds
+func OnPublish(hook PublishHook) {
+	publishHooks = append(publishHooks, hook)
+}
+
+// CreatePost inserts a new post and notifies the publish hooks
+func CreatePost(title, description, markdown string) (*Post, error) {
+	res, err := DB.Exec("INSERT INTO blogs (title, description, markdown) VALUES (?, ?, ?)", title, description, markdown)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return publish(int(id))
+}
+
+func publish(id int) (*Post, error) {
+	post, err := GetBlogByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range publishHooks {
+		hook(post)
+	}
+
+	return post, nil
+}
+
+func updatedOrCreated(updatedAt sql.NullTime, createdAt time.Time) time.Time {
+	if updatedAt.Valid {
+		return updatedAt.Time
+	}
+	return createdAt
+}
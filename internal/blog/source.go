@@ -0,0 +1,123 @@
+package blog
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SaveMarkdownDocument creates or updates the post synced from doc.Filepath. Posts are only
+// rewritten when the commit changed, and new posts run the publish hooks when notify is set.
+func SaveMarkdownDocument(doc *MarkdownDocument, notify bool) (created bool, updated bool, err error) {
+	var (
+		id       int
+		sha      sql.NullString
+		archived bool
+	)
+	err = DB.QueryRow("SELECT id, commit_sha, archived FROM blogs WHERE source_path = ?", doc.Filepath).Scan(&id, &sha, &archived)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		published := doc.Published
+		if published.IsZero() {
+			published = time.Now()
+		}
+
+		res, err := DB.Exec(`
+			INSERT INTO blogs (title, description, markdown, created_at, updated_at, source_path, commit_sha, commit_author)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			doc.Title, doc.Description, doc.Markdown, published.UTC(), doc.LastUpdated.UTC(), doc.Filepath, doc.CommitSHA, doc.Author,
+		)
+		if err != nil {
+			return false, false, err
+		}
+
+		newID, err := res.LastInsertId()
+		if err != nil {
+			return false, false, err
+		}
+
+		if !notify {
+			return true, false, nil
+		}
+		if _, err := publish(int(newID)); err != nil {
+			return true, false, err
+		}
+		return true, false, nil
+
+	case err != nil:
+		return false, false, err
+	}
+
+	if sha.String == doc.CommitSHA && !archived {
+		return false, false, nil
+	}
+
+	_, err = DB.Exec(`
+		UPDATE blogs SET title = ?, description = ?, markdown = ?, updated_at = ?, commit_sha = ?, commit_author = ?, archived = 0
+		WHERE id = ?`,
+		doc.Title, doc.Description, doc.Markdown, doc.LastUpdated.UTC(), doc.CommitSHA, doc.Author, id,
+	)
+	if err != nil {
+		return false, false, err
+	}
+	return false, true, nil
+}
+
+// HasSyncedDocuments reports whether any post was ever synced from a file
+func HasSyncedDocuments() (bool, error) {
+	var exists bool
+	err := DB.QueryRow("SELECT EXISTS (SELECT 1 FROM blogs WHERE source_path IS NOT NULL)").Scan(&exists)
+	return exists, err
+}
+
+// HasDocument reports whether a post is synced from file, archived or not
+func HasDocument(file string) (bool, error) {
+	var exists bool
+	err := DB.QueryRow("SELECT EXISTS (SELECT 1 FROM blogs WHERE source_path = ?)", file).Scan(&exists)
+	return exists, err
+}
+
+// RenameDocument moves the post synced from oldPath over to newPath, keeping its ID, URL and
+// webmentions. It reports false when no post was synced from oldPath.
+func RenameDocument(oldPath, newPath string) (bool, error) {
+	res, err := DB.Exec("UPDATE blogs SET source_path = ? WHERE source_path = ?", newPath, oldPath)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveMissingDocuments archives (or deletes) synced posts whose file is not in paths.
+// Posts created outside of the sync are never touched.
+func RemoveMissingDocuments(paths []string, hardDelete bool) (int64, error) {
+	where := "source_path IS NOT NULL AND archived = 0"
+	args := make([]any, 0, len(paths))
+	if len(paths) > 0 {
+		where += " AND source_path NOT IN (?" + strings.Repeat(", ?", len(paths)-1) + ")"
+		for _, p := range paths {
+			args = append(args, p)
+		}
+	}
+
+	if hardDelete {
+		_, err := DB.Exec("DELETE FROM webmentions WHERE post_id IN (SELECT id FROM blogs WHERE "+where+")", args...)
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := DB.Exec("DELETE FROM blogs WHERE "+where, args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	res, err := DB.Exec("UPDATE blogs SET archived = 1, updated_at = ? WHERE "+where, append([]any{time.Now().UTC()}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,61 @@
+package blog
+
+import (
+	"time"
+)
+
+type Webmention struct {
+	ID         int       `json:"id"`
+	PostID     int       `json:"postId"`
+	Source     string    `json:"source"`
+	Target     string    `json:"target"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+func initWebmentions() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS webmentions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+		source TEXT NOT NULL,
+		target TEXT NOT NULL,
+		verified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(source, target)
+	);`
+	_, err := DB.Exec(query)
+	return err
+}
+
+// SaveWebmention stores a verified mention, refreshing the timestamp if it was already known
+func SaveWebmention(postID int, source, target string) error {
+	_, err := DB.Exec(`
+		INSERT INTO webmentions (post_id, source, target, verified_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(source, target) DO UPDATE SET post_id = excluded.post_id, verified_at = excluded.verified_at`,
+		postID, source, target, time.Now().UTC(),
+	)
+	return err
+}
+
+// DeleteWebmention removes a mention whose source no longer links to us
+func DeleteWebmention(source, target string) error {
+	_, err := DB.Exec("DELETE FROM webmentions WHERE source = ? AND target = ?", source, target)
+	return err
+}
+
+func GetWebmentions(postID int) ([]Webmention, error) {
+	rows, err := DB.Query("SELECT id, post_id, source, target, verified_at FROM webmentions WHERE post_id = ? ORDER BY verified_at DESC", postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := make([]Webmention, 0)
+	for rows.Next() {
+		var m Webmention
+		if err := rows.Scan(&m.ID, &m.PostID, &m.Source, &m.Target, &m.VerifiedAt); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
@@ -0,0 +1,145 @@
+package cards
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"tringldev-server/internal/albumart"
+)
+
+// Theme holds the colours of a card as CSS colour values
+type Theme struct {
+	Background string
+	Border     string
+	Text       string
+	Muted      string
+	Accent     string
+}
+
+var themes = map[string]Theme{
+	"dark": {
+		Background: "#11111b",
+		Border:     "#2a2a3a",
+		Text:       "#f5f5f5",
+		Muted:      "#9a9aad",
+		Accent:     "#58b9ff",
+	},
+	"light": {
+		Background: "#ffffff",
+		Border:     "#e1e4e8",
+		Text:       "#24292f",
+		Muted:      "#57606a",
+		Accent:     "#0969da",
+	},
+}
+
+// ParseTheme starts from the named theme (dark when empty) and applies any colour overrides,
+// given as hex without the leading # so they survive in a README image URL
+func ParseTheme(name string, overrides map[string]string) (Theme, error) {
+	if name == "" {
+		name = "dark"
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q, expected dark or light", name)
+	}
+
+	fields := map[string]*string{
+		"bg_color":     &theme.Background,
+		"border_color": &theme.Border,
+		"text_color":   &theme.Text,
+		"muted_color":  &theme.Muted,
+		"accent_color": &theme.Accent,
+	}
+	for param, value := range overrides {
+		field, ok := fields[param]
+		if !ok || value == "" {
+			continue
+		}
+		if !isHexColor(value) {
+			return Theme{}, fmt.Errorf("invalid %s %q, expected hex like 0d1117", param, value)
+		}
+		*field = "#" + value
+	}
+
+	return theme, nil
+}
+
+// ColorParams lists the query parameters ParseTheme reads overrides from
+var ColorParams = []string{"bg_color", "border_color", "text_color", "muted_color", "accent_color"}
+
+func isHexColor(s string) bool {
+	switch len(s) {
+	case 3, 4, 6, 8:
+	default:
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Service renders cards with their album art embedded. Images in a README are proxied by
+// GitHub's camo, which doesn't load anything referenced from inside an SVG.
+type Service struct {
+	art *albumart.Service
+}
+
+func NewService(art *albumart.Service) *Service {
+	return &Service{art: art}
+}
+
+// artDataURI loads album art through the proxy and returns it as a data URI, or "" if there
+// is none. The card shows art at 88px, so the 128px size keeps it sharp on high-DPI screens.
+func (s *Service) artDataURI(ctx context.Context, artURL string) string {
+	if artURL == "" {
+		return ""
+	}
+
+	img, err := s.art.Load(ctx, artURL, 128)
+	if err != nil {
+		if !errors.Is(err, albumart.ErrNotFound) {
+			log.Printf("Failed to load album art for card: %v\n", err)
+		}
+		return ""
+	}
+	if img.Placeholder {
+		return ""
+	}
+
+	return "data:" + img.ContentType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis when cut
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+func periodLabel(period string) string {
+	switch period {
+	case "1month", "monthly":
+		return "last month"
+	case "3month":
+		return "last 3 months"
+	case "6month":
+		return "last 6 months"
+	case "12month", "yearly":
+		return "last year"
+	case "overall", "alltime":
+		return "all time"
+	default:
+		return "last 7 days"
+	}
+}
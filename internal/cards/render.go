@@ -0,0 +1,197 @@
+package cards
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"strconv"
+	"text/template"
+
+	"tringldev-server/internal/lastfm"
+)
+
+const cardWidth = 480
+
+var funcs = template.FuncMap{
+	// Escapes text and attribute values, template data is untrusted Last.fm metadata
+	"esc":    html.EscapeString,
+	"minus1": func(n int) int { return n - 1 },
+}
+
+var nowPlayingTemplate = template.Must(template.New("now-playing").Funcs(funcs).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="120" viewBox="0 0 {{.Width}} 120" role="img" aria-label="{{esc .Label}}">
+  <title>{{esc .Label}}</title>
+  <style>
+    .label { font: 600 12px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Accent}}; letter-spacing: 0.5px; }
+    .song { font: 600 16px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Text}}; }
+    .artist { font: 400 14px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Muted}}; }
+    .album { font: 400 12px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Muted}}; }
+  </style>
+  <rect x="0.5" y="0.5" width="{{.Width | minus1}}" height="119" rx="8" fill="{{.Theme.Background}}" stroke="{{.Theme.Border}}"/>
+  <clipPath id="art"><rect x="16" y="16" width="88" height="88" rx="6"/></clipPath>
+  {{- if .Art}}
+  <image x="16" y="16" width="88" height="88" href="{{.Art}}" clip-path="url(#art)" preserveAspectRatio="xMidYMid slice"/>
+  {{- else}}
+  <rect x="16" y="16" width="88" height="88" rx="6" fill="{{.Theme.Border}}"/>
+  <text x="60" y="68" text-anchor="middle" style="font: 32px sans-serif; fill: {{.Theme.Muted}}">♪</text>
+  {{- end}}
+  {{- if .Playing}}
+  <g fill="{{.Theme.Accent}}">
+    <rect x="120" y="22" width="3" height="10"><animate attributeName="height" values="10;4;10" dur="0.9s" repeatCount="indefinite"/></rect>
+    <rect x="125" y="22" width="3" height="6"><animate attributeName="height" values="6;10;6" dur="0.7s" repeatCount="indefinite"/></rect>
+    <rect x="130" y="22" width="3" height="8"><animate attributeName="height" values="8;3;8" dur="0.8s" repeatCount="indefinite"/></rect>
+  </g>
+  <text x="140" y="32" class="label">NOW PLAYING</text>
+  {{- else}}
+  <text x="120" y="32" class="label">LAST PLAYED</text>
+  {{- end}}
+  <text x="120" y="58" class="song">{{esc .Song}}</text>
+  <text x="120" y="80" class="artist">{{esc .Artist}}</text>
+  <text x="120" y="100" class="album">{{esc .Album}}</text>
+</svg>
+`))
+
+var topArtistsTemplate = template.Must(template.New("top-artists").Funcs(funcs).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img" aria-label="{{esc .Title}}">
+  <title>{{esc .Title}}</title>
+  <style>
+    .title { font: 600 16px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Accent}}; }
+    .name { font: 400 14px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Text}}; }
+    .count { font: 400 12px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Muted}}; }
+  </style>
+  <rect x="0.5" y="0.5" width="{{.Width | minus1}}" height="{{.Height | minus1}}" rx="8" fill="{{.Theme.Background}}" stroke="{{.Theme.Border}}"/>
+  <text x="20" y="34" class="title">{{esc .Title}}</text>
+  {{- range $i, $row := .Rows}}
+  <g transform="translate(20, {{$row.Y}})">
+    <text x="0" y="0" class="name">{{$row.Rank}}. {{esc $row.Name}}</text>
+    <text x="{{$.CountX}}" y="0" text-anchor="end" class="count">{{esc $row.PlayCount}} plays</text>
+    <rect x="0" y="8" width="{{$.BarWidth}}" height="4" rx="2" fill="{{$.Theme.Border}}"/>
+    <rect x="0" y="8" width="{{$row.Bar}}" height="4" rx="2" fill="{{$.Theme.Accent}}"/>
+  </g>
+  {{- end}}
+  {{- if not .Rows}}
+  <text x="20" y="70" class="count">No plays in this period</text>
+  {{- end}}
+</svg>
+`))
+
+// NowPlaying renders the current or last played track with its album art
+func (s *Service) NowPlaying(ctx context.Context, info *lastfm.NowPlayingInfo, theme Theme) ([]byte, error) {
+	data := struct {
+		Width   int
+		Theme   Theme
+		Label   string
+		Playing bool
+		Art     string
+		Song    string
+		Artist  string
+		Album   string
+	}{
+		Width:   cardWidth,
+		Theme:   theme,
+		Playing: info.IsPlaying,
+		Art:     s.artDataURI(ctx, info.AlbumArt),
+		Song:    truncate(info.SongName, 38),
+		Artist:  truncate(info.ArtistName, 44),
+		Album:   truncate(info.AlbumName, 50),
+	}
+
+	if data.Song == "" {
+		data.Song = "Nothing played yet"
+	}
+	data.Label = "Last played: " + info.SongName
+	if info.IsPlaying {
+		data.Label = "Now playing: " + info.SongName
+	}
+	if info.ArtistName != "" {
+		data.Label += " by " + info.ArtistName
+	}
+
+	var buf bytes.Buffer
+	if err := nowPlayingTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TopArtists renders a ranked list with bars scaled to the top artist's playcount
+func (s *Service) TopArtists(info *lastfm.TopArtistsInfo, period string, theme Theme) ([]byte, error) {
+	type row struct {
+		Rank      int
+		Name      string
+		PlayCount string
+		Y         int
+		Bar       int
+	}
+
+	const (
+		rowHeight = 36
+		barWidth  = cardWidth - 40
+	)
+
+	top := 0
+	for _, artist := range info.Artists {
+		if n, _ := strconv.Atoi(artist.PlayCount); n > top {
+			top = n
+		}
+	}
+
+	rows := make([]row, 0, len(info.Artists))
+	for i, artist := range info.Artists {
+		plays, _ := strconv.Atoi(artist.PlayCount)
+		bar := 0
+		if top > 0 {
+			bar = max(plays*barWidth/top, 4)
+		}
+		rows = append(rows, row{
+			Rank:      i + 1,
+			Name:      truncate(artist.Name, 40),
+			PlayCount: artist.PlayCount,
+			Y:         70 + i*rowHeight,
+			Bar:       bar,
+		})
+	}
+
+	height := 70 + max(len(rows), 1)*rowHeight
+	data := struct {
+		Width    int
+		Height   int
+		Theme    Theme
+		Title    string
+		Rows     []row
+		CountX   int
+		BarWidth int
+	}{
+		Width:    cardWidth,
+		Height:   height,
+		Theme:    theme,
+		Title:    "Top artists · " + periodLabel(period),
+		Rows:     rows,
+		CountX:   barWidth,
+		BarWidth: barWidth,
+	}
+
+	var buf bytes.Buffer
+	if err := topArtistsTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var errorTemplate = template.Must(template.New("error").Funcs(funcs).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="60" viewBox="0 0 {{.Width}} 60" role="img" aria-label="{{esc .Message}}">
+  <rect x="0.5" y="0.5" width="{{.Width | minus1}}" height="59" rx="8" fill="{{.Theme.Background}}" stroke="{{.Theme.Border}}"/>
+  <text x="20" y="35" style="font: 400 14px 'Segoe UI', Ubuntu, sans-serif; fill: {{.Theme.Muted}}">{{esc .Message}}</text>
+</svg>
+`))
+
+// Error renders a card carrying message, for when the data behind a card is unavailable
+func (s *Service) Error(message string, theme Theme) []byte {
+	data := struct {
+		Width   int
+		Theme   Theme
+		Message string
+	}{cardWidth, theme, truncate(message, 60)}
+
+	var buf bytes.Buffer
+	// The template and its inputs are fixed, so this can't fail
+	_ = errorTemplate.Execute(&buf, data)
+	return buf.Bytes()
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -10,12 +13,68 @@ import (
 type Config struct {
 	LastFMAPIKey   string
 	LastFMUsername string
+	// Last.fm users served under /api/users/{user}, LastFMUsername first
+	LastFMUsers []string
+
+	// Where listening data for /api/now-playing, top-* and friends comes from: lastfm or listenbrainz
+	MusicProvider        string
+	ListenBrainzUsername string
+	ListenBrainzToken    string
+	ListenBrainzURL      string
+
 	GithubToken    string
 	GithubUsername string
 	Port           string
 	DiscordWebhook string
 	WhitelistedIPs []string
 	AllowedOrigins []string
+	PublicURL      string
+	SiteURL        string
+	OGCacheDir     string
+	// Handle the blog is followed as from the Fediverse, e.g. @blog@api.tringl.dev
+	ActivityPubUsername string
+
+	// Git repository of Markdown posts synced into the blogs table, disabled when empty
+	ContentRepo          string
+	ContentRef           string
+	ContentSyncInterval  time.Duration
+	ContentWebhookSecret string
+	ContentDeleteRemoved bool
+
+	// How often the local scrobble archive catches up with Last.fm, 0 disables it
+	ScrobbleSyncInterval time.Duration
+
+	// How often the now-playing hub asks Last.fm for changes while anyone is listening
+	NowPlayingPollInterval time.Duration
+	// Limit on concurrent SSE and WebSocket now-playing subscribers
+	NowPlayingMaxSubscribers int
+
+	// Disk cache for proxied album art and its size limit
+	ArtCacheDir      string
+	ArtCacheMaxBytes int64
+
+	// Lowercase Last.fm tags that aren't genres, left out of artist tags and /api/genres
+	GenreDenylist []string
+
+	// Discord webhook for listening milestones, DiscordWebhook unless set
+	NotifyWebhook string
+	// How often the milestone watcher checks Last.fm, 0 disables it
+	MilestoneCheckInterval time.Duration
+	// Total scrobble counts announced when crossed
+	ScrobbleMilestones []int
+	// Play counts announced when a single track reaches them
+	TrackPlayMilestones []int
+}
+
+var (
+	defaultScrobbleMilestones  = []int{1000, 5000, 10000, 25000, 50000, 75000, 100000, 150000, 200000, 250000, 500000, 1000000}
+	defaultTrackPlayMilestones = []int{100, 250, 500, 1000}
+)
+
+var defaultGenreDenylist = []string{
+	"seen live", "favorites", "favourites", "favorite", "favourite", "my favorite",
+	"albums i own", "under 2000 listeners", "spotify", "love", "awesome", "beautiful",
+	"male vocalists", "female vocalists", "all", "music", "good",
 }
 
 func Load() *Config {
@@ -27,10 +86,28 @@ func Load() *Config {
 	cfg := &Config{
 		LastFMAPIKey:   os.Getenv("LASTFM_API_KEY"),
 		LastFMUsername: os.Getenv("LASTFM_USERNAME"),
+
+		MusicProvider:        os.Getenv("MUSIC_PROVIDER"),
+		ListenBrainzUsername: os.Getenv("LISTENBRAINZ_USERNAME"),
+		ListenBrainzToken:    os.Getenv("LISTENBRAINZ_TOKEN"),
+		ListenBrainzURL:      os.Getenv("LISTENBRAINZ_URL"),
+
 		GithubToken:    os.Getenv("GITHUB_TOKEN"),
 		GithubUsername: os.Getenv("GITHUB_USERNAME"),
 		Port:           os.Getenv("PORT"),
 		DiscordWebhook: os.Getenv("DISCORD_WEBHOOK"),
+		PublicURL:      os.Getenv("PUBLIC_URL"),
+		SiteURL:        os.Getenv("SITE_URL"),
+		OGCacheDir:     os.Getenv("OG_CACHE_DIR"),
+		ArtCacheDir:    os.Getenv("ART_CACHE_DIR"),
+		NotifyWebhook:  os.Getenv("NOTIFY_DISCORD_WEBHOOK"),
+
+		ActivityPubUsername: os.Getenv("ACTIVITYPUB_USERNAME"),
+
+		ContentRepo:          os.Getenv("CONTENT_REPO"),
+		ContentRef:           os.Getenv("CONTENT_REF"),
+		ContentWebhookSecret: os.Getenv("CONTENT_WEBHOOK_SECRET"),
+		ContentDeleteRemoved: os.Getenv("CONTENT_DELETE_REMOVED") == "true",
 	}
 
 	if whitelistedIPs := os.Getenv("WHITELISTED_IPS"); whitelistedIPs != "" {
@@ -51,16 +128,90 @@ func Load() *Config {
 		cfg.AllowedOrigins = []string{"*"}
 	}
 
+	// The site owner is always on the music wall, whether or not LASTFM_USERS lists them
+	if cfg.LastFMUsername != "" {
+		cfg.LastFMUsers = []string{cfg.LastFMUsername}
+	}
+	for _, user := range splitAndTrim(os.Getenv("LASTFM_USERS"), ",") {
+		duplicate := false
+		for _, existing := range cfg.LastFMUsers {
+			if strings.EqualFold(existing, user) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			cfg.LastFMUsers = append(cfg.LastFMUsers, user)
+		}
+	}
+
+	if genreDenylist := os.Getenv("GENRE_DENYLIST"); genreDenylist != "" {
+		for _, tag := range splitAndTrim(genreDenylist, ",") {
+			cfg.GenreDenylist = append(cfg.GenreDenylist, strings.ToLower(tag))
+		}
+	} else {
+		cfg.GenreDenylist = defaultGenreDenylist
+	}
+
 	if cfg.Port == "" {
 		cfg.Port = "8080"
 	}
 
+	// Trailing slashes would double up when absolute URLs are built from these
+	cfg.PublicURL = trimTrailingSlash(cfg.PublicURL)
+	cfg.SiteURL = trimTrailingSlash(cfg.SiteURL)
+
+	switch cfg.MusicProvider {
+	case "":
+		cfg.MusicProvider = "lastfm"
+	case "lastfm", "listenbrainz":
+	default:
+		log.Printf("Warning: unknown MUSIC_PROVIDER %q, using lastfm\n", cfg.MusicProvider)
+		cfg.MusicProvider = "lastfm"
+	}
+	if cfg.ListenBrainzURL == "" {
+		cfg.ListenBrainzURL = "https://api.listenbrainz.org"
+	}
+	cfg.ListenBrainzURL = trimTrailingSlash(cfg.ListenBrainzURL)
+
+	if cfg.OGCacheDir == "" {
+		cfg.OGCacheDir = "./cache/og"
+	}
+
+	if cfg.ArtCacheDir == "" {
+		cfg.ArtCacheDir = "./cache/art"
+	}
+	cfg.ArtCacheMaxBytes = int64(parseInt("ART_CACHE_MAX_MB", 200)) << 20
+
+	if cfg.ActivityPubUsername == "" {
+		cfg.ActivityPubUsername = "blog"
+	}
+
+	if cfg.ContentRef == "" {
+		cfg.ContentRef = "HEAD"
+	}
+
+	cfg.ContentSyncInterval = parseDuration("CONTENT_SYNC_INTERVAL", 5*time.Minute, false)
+	cfg.ScrobbleSyncInterval = parseDuration("SCROBBLE_SYNC_INTERVAL", 5*time.Minute, true)
+	cfg.NowPlayingPollInterval = parseDuration("NOW_PLAYING_POLL_INTERVAL", 15*time.Second, false)
+	cfg.NowPlayingMaxSubscribers = parseInt("NOW_PLAYING_MAX_SUBSCRIBERS", 200)
+
+	if cfg.NotifyWebhook == "" {
+		cfg.NotifyWebhook = cfg.DiscordWebhook
+	}
+	cfg.MilestoneCheckInterval = parseDuration("MILESTONE_CHECK_INTERVAL", 15*time.Minute, true)
+	cfg.ScrobbleMilestones = parseIntList("SCROBBLE_MILESTONES", defaultScrobbleMilestones)
+	cfg.TrackPlayMilestones = parseIntList("TRACK_PLAY_MILESTONES", defaultTrackPlayMilestones)
+
 	if cfg.LastFMAPIKey == "" {
 		log.Println("Warning: LASTFM_API_KEY not set")
 	}
 	if cfg.LastFMUsername == "" {
 		log.Println("Warning: LASTFM_USERNAME not set")
 	}
+	if cfg.MusicProvider == "listenbrainz" && cfg.ListenBrainzUsername == "" {
+		log.Println("Warning: MUSIC_PROVIDER is listenbrainz but LISTENBRAINZ_USERNAME is not set")
+	}
 	if cfg.GithubToken == "" {
 		log.Println("Warning: GITHUB_TOKEN not set")
 	}
@@ -70,10 +221,68 @@ func Load() *Config {
 	if cfg.DiscordWebhook == "" {
 		log.Println("Warning: DISCORD_WEBHOOK not set")
 	}
+	if cfg.PublicURL == "" {
+		log.Println("Warning: PUBLIC_URL not set, generated links will be relative")
+	}
+	if cfg.SiteURL == "" {
+		log.Println("Warning: SITE_URL not set, webmentions are disabled")
+	}
 
 	return cfg
 }
 
+// parseDuration reads a duration like "5m" from the environment, falling back to def when it
+// is unset or invalid. allowZero lets "0" through so a feature can be switched off.
+func parseDuration(key string, def time.Duration, allowZero bool) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 || (parsed == 0 && !allowZero) {
+		log.Printf("Warning: invalid %s %q, using %s\n", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// parseInt reads a positive integer from the environment, falling back to def when it is
+// unset or invalid
+func parseInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("Warning: invalid %s %q, using %d\n", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// parseIntList reads a comma-separated list of positive integers from the environment, falling
+// back to def when it is unset or any entry is invalid
+func parseIntList(key string, def []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	var parsed []int
+	for _, item := range splitAndTrim(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n <= 0 {
+			log.Printf("Warning: invalid %s %q, using the defaults\n", key, value)
+			return def
+		}
+		parsed = append(parsed, n)
+	}
+	return parsed
+}
+
 func splitAndTrim(s, sep string) []string {
 	var result []string
 	for i := 0; i < len(s); {
@@ -98,6 +307,13 @@ func splitAndTrim(s, sep string) []string {
 	return result
 }
 
+func trimTrailingSlash(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '/' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
 func trimSpace(s string) string {
 	start := 0
 	end := len(s)
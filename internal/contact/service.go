@@ -35,6 +35,7 @@ type DiscordEmbed struct {
 	Description string              `json:"description"`
 	Color       int                 `json:"color"`
 	Fields      []DiscordEmbedField `json:"fields"`
+	URL         string              `json:"url,omitempty"`
 	Timestamp   string              `json:"timestamp"`
 }
 
@@ -96,16 +97,34 @@ func (s *Service) SendToDiscord(req *ContactRequest) error {
 		})
 	}
 
-	webhook := DiscordWebhook{
+	return s.PostWebhook(s.config.DiscordWebhook, DiscordWebhook{
 		Embeds: []DiscordEmbed{embed},
+	})
+}
+
+// Notify posts an embed to the notification webhook, NOTIFY_DISCORD_WEBHOOK or else the
+// contact form's
+func (s *Service) Notify(embed DiscordEmbed) error {
+	if s.config.NotifyWebhook == "" {
+		return fmt.Errorf("discord webhook not configured")
+	}
+	if embed.Timestamp == "" {
+		embed.Timestamp = time.Now().Format(time.RFC3339)
 	}
 
+	return s.PostWebhook(s.config.NotifyWebhook, DiscordWebhook{
+		Embeds: []DiscordEmbed{embed},
+	})
+}
+
+// PostWebhook sends a message to a Discord webhook URL
+func (s *Service) PostWebhook(webhookURL string, webhook DiscordWebhook) error {
 	payload, err := json.Marshal(webhook)
 	if err != nil {
 		return fmt.Errorf("failed to marshal discord webhook: %w", err)
 	}
 
-	resp, err := http.Post(s.config.DiscordWebhook, "application/json", bytes.NewBuffer(payload))
+	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to send discord webhook: %w", err)
 	}
@@ -0,0 +1,392 @@
+package content
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Syncer mirrors a git repository of Markdown files into the blogs table
+type Syncer struct {
+	config  *config.Config
+	trigger chan struct{}
+
+	mu         sync.Mutex
+	lastCommit string
+}
+
+type Result struct {
+	Commit  string `json:"commit"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Removed int64  `json:"removed"`
+}
+
+type frontMatter struct {
+	Title       string    `yaml:"title"`
+	Description string    `yaml:"description"`
+	Date        time.Time `yaml:"date"`
+	Draft       bool      `yaml:"draft"`
+}
+
+func NewSyncer(cfg *config.Config) *Syncer {
+	return &Syncer{
+		config:  cfg,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+func (s *Syncer) Enabled() bool {
+	return s.config.ContentRepo != ""
+}
+
+// Start syncs once immediately, then on every interval tick or Trigger call
+func (s *Syncer) Start() {
+	if !s.Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.config.ContentSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			result, err := s.Sync()
+			if err != nil {
+				log.Printf("Content sync failed: %v\n", err)
+			} else if result.Created > 0 || result.Updated > 0 || result.Removed > 0 {
+				log.Printf("Content synced at %s: %d created, %d updated, %d removed\n",
+					shortSHA(result.Commit), result.Created, result.Updated, result.Removed)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
+
+// Trigger asks the background loop to sync now, coalescing with any pending request
+func (s *Syncer) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// VerifyWebhook checks a GitHub/Gitea style X-Hub-Signature-256 header against the body
+func (s *Syncer) VerifyWebhook(body []byte, signature string) bool {
+	if s.config.ContentWebhookSecret == "" {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.config.ContentWebhookSecret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Sync pulls the repository if it has a remote and reconciles every Markdown file at the
+// configured ref into the blogs table. Posts whose file disappeared are archived or deleted.
+func (s *Syncer) Sync() (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pull()
+
+	commit, err := s.git("rev-parse", "--verify", s.config.ContentRef+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", s.config.ContentRef, err)
+	}
+	commit = strings.TrimSpace(commit)
+
+	result := &Result{Commit: commit}
+	if commit == s.lastCommit {
+		return result, nil
+	}
+
+	files, err := s.git("ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	// The first import of an existing repository must not announce every historical post
+	initial, err := s.initialImport()
+	if err != nil {
+		return nil, err
+	}
+	if initial {
+		log.Println("Importing content repository for the first time, skipping publish hooks")
+	}
+
+	tree := make(map[string]bool)
+	for _, file := range strings.Split(files, "\x00") {
+		tree[file] = true
+	}
+
+	paths := make([]string, 0)
+	for _, file := range strings.Split(files, "\x00") {
+		if !strings.EqualFold(path.Ext(file), ".md") || strings.EqualFold(path.Base(file), "README.md") {
+			continue
+		}
+
+		doc, err := s.readDocument(commit, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if doc == nil {
+			continue // draft
+		}
+		paths = append(paths, file)
+
+		if !initial {
+			if err := s.followRename(commit, file, tree); err != nil {
+				return nil, fmt.Errorf("failed to follow renames of %s: %w", file, err)
+			}
+		}
+
+		created, updated, err := blog.SaveMarkdownDocument(doc, !initial)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save %s: %w", file, err)
+		}
+		if created {
+			result.Created++
+		}
+		if updated {
+			result.Updated++
+		}
+	}
+
+	// An empty tree is far more likely to be a misconfigured ref than a wish to remove every post
+	if len(paths) == 0 {
+		log.Printf("Warning: no Markdown posts found in %s at %s, skipping removal\n", s.config.ContentRepo, shortSHA(commit))
+	} else {
+		removed, err := blog.RemoveMissingDocuments(paths, s.config.ContentDeleteRemoved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove missing posts: %w", err)
+		}
+		result.Removed = removed
+	}
+
+	s.lastCommit = commit
+	return result, nil
+}
+
+// pull fetches from the remote when the repository has one; local-only repos are read as they are.
+// Bare repositories mirror the remote branches, so HEAD follows them. Clones fast-forward the
+// checked-out branch to its upstream.
+func (s *Syncer) pull() {
+	remotes, err := s.git("remote")
+	if err != nil || strings.TrimSpace(remotes) == "" {
+		return
+	}
+
+	bare, err := s.git("rev-parse", "--is-bare-repository")
+	if err != nil {
+		log.Printf("Content fetch failed, syncing local state: %v\n", err)
+		return
+	}
+
+	if strings.TrimSpace(bare) == "true" {
+		remote := strings.Fields(remotes)[0]
+		if _, err := s.git("fetch", "--prune", "--quiet", remote, "+refs/heads/*:refs/heads/*"); err != nil {
+			log.Printf("Content fetch failed, syncing local state: %v\n", err)
+		}
+		return
+	}
+
+	if _, err := s.git("fetch", "--all", "--prune", "--quiet"); err != nil {
+		log.Printf("Content fetch failed, syncing local state: %v\n", err)
+		return
+	}
+
+	// A detached HEAD or a branch without upstream is read as it is
+	if _, err := s.git("rev-parse", "--verify", "--quiet", "@{upstream}"); err != nil {
+		return
+	}
+	if _, err := s.git("merge", "--ff-only", "--quiet", "@{upstream}"); err != nil {
+		log.Printf("Content fast-forward failed, syncing local state: %v\n", err)
+	}
+}
+
+// initialImport reports whether this is the first sync into a database without synced posts
+func (s *Syncer) initialImport() (bool, error) {
+	if s.lastCommit != "" {
+		return false, nil
+	}
+
+	synced, err := blog.HasSyncedDocuments()
+	if err != nil {
+		return false, fmt.Errorf("failed to check for synced posts: %w", err)
+	}
+	return !synced, nil
+}
+
+// followRename moves the post of a file's previous path over to file when the file has no post
+// yet, so a rename updates the existing post instead of archiving it and publishing a new one
+func (s *Syncer) followRename(commit, file string, tree map[string]bool) error {
+	exists, err := blog.HasDocument(file)
+	if err != nil || exists {
+		return err
+	}
+
+	history, err := s.git("log", "--follow", "--name-only", "--format=", commit, "--", file)
+	if err != nil {
+		return err
+	}
+
+	for _, previous := range strings.Split(history, "\n") {
+		previous = strings.TrimSpace(previous)
+		if previous == "" || previous == file || tree[previous] {
+			continue
+		}
+
+		renamed, err := blog.RenameDocument(previous, file)
+		if err != nil || renamed {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDocument loads a file at commit along with its git history. Drafts return nil.
+func (s *Syncer) readDocument(commit, file string) (*blog.MarkdownDocument, error) {
+	raw, err := s.git("show", commit+":"+file)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, body, err := parseFrontMatter(raw)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Draft {
+		return nil, nil
+	}
+
+	history, err := s.git("log", "--format=%H%x1f%an%x1f%aI", commit, "--", file)
+	if err != nil {
+		return nil, err
+	}
+	entries := strings.Split(strings.TrimSpace(history), "\n")
+
+	latest := strings.Split(entries[0], "\x1f")
+	first := strings.Split(entries[len(entries)-1], "\x1f")
+	if len(latest) != 3 || len(first) != 3 {
+		return nil, fmt.Errorf("unexpected git log output %q", entries[0])
+	}
+
+	doc := &blog.MarkdownDocument{
+		Title:       meta.Title,
+		Description: meta.Description,
+		Filepath:    file,
+		Published:   meta.Date,
+		Markdown:    body,
+		CommitSHA:   latest[0],
+		Author:      latest[1],
+	}
+
+	doc.LastUpdated, err = time.Parse(time.RFC3339, latest[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse commit date: %w", err)
+	}
+	if doc.Published.IsZero() {
+		if doc.Published, err = time.Parse(time.RFC3339, first[2]); err != nil {
+			return nil, fmt.Errorf("failed to parse commit date: %w", err)
+		}
+	}
+	if doc.Title == "" {
+		doc.Title = titleFromMarkdown(body, file)
+	}
+
+	return doc, nil
+}
+
+// parseFrontMatter splits an optional leading YAML block delimited by --- lines from the body.
+// A leading --- that isn't closed, or doesn't open a YAML mapping, is part of the body.
+func parseFrontMatter(raw string) (*frontMatter, string, error) {
+	meta := &frontMatter{}
+
+	normalised := strings.ReplaceAll(raw, "\r\n", "\n")
+	rest, ok := strings.CutPrefix(normalised, "---\n")
+	if !ok {
+		return meta, raw, nil
+	}
+
+	lines := strings.Split(rest, "\n")
+	end := -1
+	for i, line := range lines {
+		if strings.TrimRight(line, " \t") == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return meta, raw, nil
+	}
+
+	header := strings.Join(lines[:end], "\n")
+	body := strings.TrimLeft(strings.Join(lines[end+1:], "\n"), "\n")
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(header), &node); err != nil {
+		return nil, "", fmt.Errorf("invalid front matter: %w", err)
+	}
+	if len(node.Content) == 0 {
+		return meta, body, nil
+	}
+	// Prose between two thematic breaks, not front matter
+	if node.Content[0].Kind != yaml.MappingNode {
+		return meta, raw, nil
+	}
+	if err := node.Decode(meta); err != nil {
+		return nil, "", fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	return meta, body, nil
+}
+
+// titleFromMarkdown falls back to the first heading, then the file name
+func titleFromMarkdown(body, file string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(line[2:])
+		}
+	}
+	return strings.TrimSuffix(path.Base(file), path.Ext(file))
+}
+
+func (s *Syncer) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", s.config.ContentRepo}, args...)...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
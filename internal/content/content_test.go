@@ -0,0 +1,286 @@
+package content
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantTitle string
+		wantDraft bool
+		wantBody  string
+	}{
+		{"none", "# Hello\n\nworld", "", false, "# Hello\n\nworld"},
+		{"full", "---\ntitle: Hello\ndescription: Short\ndate: 2025-10-06\n---\n\n# Body\n", "Hello", false, "# Body\n"},
+		{"draft", "---\ntitle: Later\ndraft: true\n---\nbody", "Later", true, "body"},
+		{"empty", "---\n---\nbody", "", false, "body"},
+		{"crlf", "---\r\ntitle: Windows\r\n---\r\nline one\r\nline two", "Windows", false, "line one\nline two"},
+		{"not closed", "---\ntitle: Open\nbody", "", false, "---\ntitle: Open\nbody"},
+		{"body starts with a rule", "---\nJust prose between rules.\n---\nMore prose", "", false, "---\nJust prose between rules.\n---\nMore prose"},
+		{"rule after front matter", "---\ntitle: Rules\n---\n---\nafter the rule", "Rules", false, "---\nafter the rule"},
+		{"closing line with trailing spaces", "---\ntitle: Spaced\n---  \nbody", "Spaced", false, "body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body, err := parseFrontMatter(tt.raw)
+			if err != nil {
+				t.Fatalf("parseFrontMatter: %v", err)
+			}
+			if meta.Title != tt.wantTitle || meta.Draft != tt.wantDraft {
+				t.Errorf("meta = %+v, want title %q draft %v", meta, tt.wantTitle, tt.wantDraft)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	if _, _, err := parseFrontMatter("---\ntitle: [unclosed\n---\nbody"); err == nil {
+		t.Error("invalid YAML front matter was accepted")
+	}
+	if meta, _, err := parseFrontMatter("---\ndate: 2025-10-06\n---\n"); err != nil || !meta.Date.Equal(time.Date(2025, 10, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date = %v, %v", meta.Date, err)
+	}
+}
+
+// git runs a git command in dir with a fixed identity
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Author", "GIT_AUTHOR_EMAIL=author@example.com",
+		"GIT_COMMITTER_NAME=Author", "GIT_COMMITTER_EMAIL=author@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+dir,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files (removing those with empty content) and commits them
+func commit(t *testing.T, dir, message string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			git(t, dir, "rm", "-q", name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "add", name)
+	}
+	git(t, dir, "commit", "-q", "-m", message)
+}
+
+func newRepo(t *testing.T, dir string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "init", "-q", "-b", "main")
+	return dir
+}
+
+// newTestDatabase opens a fresh blog database and counts the posts published through hooks
+func newTestDatabase(t *testing.T) *int {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	if err := blog.InitDatabase(); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	t.Cleanup(func() { blog.DB.Close() })
+
+	published := new(int)
+	blog.OnPublish(func(*blog.Post) { *published++ })
+	return published
+}
+
+type syncedPost struct {
+	id       int
+	title    string
+	archived bool
+}
+
+func postAt(t *testing.T, path string) *syncedPost {
+	t.Helper()
+	p := &syncedPost{}
+	err := blog.DB.QueryRow("SELECT id, title, archived FROM blogs WHERE source_path = ?", path).Scan(&p.id, &p.title, &p.archived)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+func mustSync(t *testing.T, s *Syncer) *Result {
+	t.Helper()
+	result, err := s.Sync()
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	return result
+}
+
+func TestSync(t *testing.T) {
+	published := newTestDatabase(t)
+	repo := newRepo(t, filepath.Join(t.TempDir(), "content"))
+
+	commit(t, repo, "initial", map[string]string{
+		"hello.md":       "---\ntitle: Hello\ndate: 2025-10-06\n---\nFirst post",
+		"notes.md":       "# Notes\n\nNo front matter",
+		"drafts/next.md": "---\ntitle: Next\ndraft: true\n---\nNot yet",
+		"README.md":      "# Content repo",
+		"image.txt":      "not markdown",
+	})
+
+	s := NewSyncer(&config.Config{ContentRepo: repo, ContentRef: "HEAD"})
+
+	// The first import doesn't announce existing posts
+	result := mustSync(t, s)
+	if result.Created != 2 || result.Updated != 0 || result.Removed != 0 || *published != 0 {
+		t.Fatalf("initial import = %+v with %d published, want 2 created and none published", result, *published)
+	}
+	hello, notes := postAt(t, "hello.md"), postAt(t, "notes.md")
+	if hello == nil || hello.title != "Hello" || notes == nil || notes.title != "Notes" {
+		t.Fatalf("unexpected posts: %+v, %+v", hello, notes)
+	}
+	if postAt(t, "drafts/next.md") != nil || postAt(t, "README.md") != nil {
+		t.Error("drafts and READMEs must not be synced")
+	}
+	post, err := blog.GetBlogByID(hello.id)
+	if err != nil {
+		t.Fatalf("GetBlogByID: %v", err)
+	}
+	if post.CommitSHA != git(t, repo, "rev-parse", "HEAD") || post.CommitAuthor != "Author" {
+		t.Errorf("commit = %s by %s", post.CommitSHA, post.CommitAuthor)
+	}
+	if !post.CreatedAt.Equal(time.Date(2025, 10, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CreatedAt = %v, want the front matter date", post.CreatedAt)
+	}
+
+	if result := mustSync(t, s); result.Created+result.Updated != 0 || result.Removed != 0 {
+		t.Errorf("unchanged sync = %+v", result)
+	}
+
+	// A rename keeps the post, a new file is published
+	if err := os.Mkdir(filepath.Join(repo, "posts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git(t, repo, "mv", "notes.md", "posts/notes.md")
+	commit(t, repo, "rename and add", map[string]string{
+		"hello.md": "---\ntitle: Hello again\n---\nEdited",
+		"new.md":   "# New\n\nFresh",
+	})
+	result = mustSync(t, s)
+	if result.Created != 1 || result.Updated != 2 || result.Removed != 0 {
+		t.Errorf("second sync = %+v, want 1 created and 2 updated", result)
+	}
+	if *published != 1 {
+		t.Errorf("%d posts published, want only the new one", *published)
+	}
+	if moved := postAt(t, "posts/notes.md"); moved == nil || moved.id != notes.id {
+		t.Errorf("renamed post = %+v, want id %d", moved, notes.id)
+	}
+	if postAt(t, "notes.md") != nil {
+		t.Error("the old path still has a post")
+	}
+	if edited := postAt(t, "hello.md"); edited.title != "Hello again" {
+		t.Errorf("title = %q, want the edit", edited.title)
+	}
+
+	// Removing a file archives its post, restoring it brings the post back
+	commit(t, repo, "remove", map[string]string{"hello.md": ""})
+	if result := mustSync(t, s); result.Removed != 1 {
+		t.Errorf("removal sync = %+v, want 1 removed", result)
+	}
+	if archived := postAt(t, "hello.md"); archived == nil || !archived.archived {
+		t.Errorf("removed post = %+v, want it archived", archived)
+	}
+
+	commit(t, repo, "restore", map[string]string{"hello.md": "# Hello\n\nBack"})
+	if result := mustSync(t, s); result.Updated != 1 || result.Created != 0 {
+		t.Errorf("restore sync = %+v, want 1 updated", result)
+	}
+	if restored := postAt(t, "hello.md"); restored.archived || restored.id != hello.id {
+		t.Errorf("restored post = %+v", restored)
+	}
+	if *published != 1 {
+		t.Errorf("%d posts published, restoring must not publish again", *published)
+	}
+}
+
+func TestSyncDeletesRemoved(t *testing.T) {
+	newTestDatabase(t)
+	repo := newRepo(t, filepath.Join(t.TempDir(), "content"))
+	commit(t, repo, "initial", map[string]string{"a.md": "# A", "b.md": "# B"})
+
+	s := NewSyncer(&config.Config{ContentRepo: repo, ContentRef: "HEAD", ContentDeleteRemoved: true})
+	mustSync(t, s)
+
+	commit(t, repo, "remove", map[string]string{"a.md": ""})
+	if result := mustSync(t, s); result.Removed != 1 {
+		t.Errorf("result = %+v, want 1 removed", result)
+	}
+	if postAt(t, "a.md") != nil {
+		t.Error("removed post still exists")
+	}
+
+	// An empty tree is treated as a mistake, not a request to remove everything
+	commit(t, repo, "empty", map[string]string{"b.md": ""})
+	if result := mustSync(t, s); result.Removed != 0 || postAt(t, "b.md") == nil {
+		t.Errorf("empty tree removed posts: %+v", result)
+	}
+}
+
+func TestSyncPullsFromRemote(t *testing.T) {
+	for _, bare := range []bool{false, true} {
+		name := "clone"
+		if bare {
+			name = "bare"
+		}
+		t.Run(name, func(t *testing.T) {
+			newTestDatabase(t)
+			dir := t.TempDir()
+			upstream := newRepo(t, filepath.Join(dir, "upstream"))
+			commit(t, upstream, "initial", map[string]string{"a.md": "# A"})
+
+			args := []string{"clone", "-q", upstream, filepath.Join(dir, "content")}
+			if bare {
+				args = []string{"clone", "-q", "--bare", upstream, filepath.Join(dir, "content")}
+			}
+			git(t, dir, args...)
+
+			s := NewSyncer(&config.Config{ContentRepo: filepath.Join(dir, "content"), ContentRef: "HEAD"})
+			if result := mustSync(t, s); result.Created != 1 {
+				t.Fatalf("first sync = %+v", result)
+			}
+
+			// HEAD follows the remote branch
+			commit(t, upstream, "more", map[string]string{"b.md": "# B"})
+			result := mustSync(t, s)
+			if result.Created != 1 || result.Commit != git(t, upstream, "rev-parse", "HEAD") {
+				t.Errorf("sync after push = %+v, want b.md at upstream HEAD", result)
+			}
+		})
+	}
+}
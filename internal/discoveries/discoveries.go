@@ -0,0 +1,214 @@
+package discoveries
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/lastfm"
+	"tringldev-server/internal/memo"
+	"tringldev-server/internal/scrobbles"
+)
+
+const (
+	// Longest window accepted, a year of plays is already over a hundred pages without the archive
+	maxDays = 365
+
+	// Scrobbles per user.getrecenttracks page, Last.fm's maximum
+	pageSize = 200
+
+	// Pause between pages so a long window doesn't trip Last.fm's rate limit
+	pageDelay = 250 * time.Millisecond
+
+	// Discoveries are recomputed this often per period
+	resultTTL = time.Hour
+
+	// Upper bound on one computation when it has to page through Last.fm
+	computeTimeout = 5 * time.Minute
+)
+
+var ErrInvalidPeriod = errors.New("period must be a number of days or weeks up to a year, e.g. 30d or 4w")
+
+// Discoveries lists the artists first scrobbled within a window ending now
+type Discoveries struct {
+	Period string    `json:"period"`
+	From   time.Time `json:"from"`
+	// archive when read from the local scrobble archive, lastfm when paged from Last.fm
+	Source  string                  `json:"source"`
+	Artists []scrobbles.FirstListen `json:"artists"`
+}
+
+// Service finds newly discovered artists, from the scrobble archive once it holds the whole
+// history and from Last.fm otherwise
+type Service struct {
+	lastfm  *lastfm.Service
+	archive *scrobbles.Archive
+	// Keyed by window length, which ParsePeriod caps at maxDays
+	results *memo.Cache[int, *Discoveries]
+}
+
+func NewService(service *lastfm.Service, archive *scrobbles.Archive) *Service {
+	return &Service{
+		lastfm:  service,
+		archive: archive,
+		results: memo.New[int, *Discoveries](resultTTL, computeTimeout),
+	}
+}
+
+// ParsePeriod reads a window such as 30d or 12w and returns its length in days
+func ParsePeriod(period string) (int, error) {
+	if len(period) < 2 {
+		return 0, ErrInvalidPeriod
+	}
+
+	n, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || n <= 0 {
+		return 0, ErrInvalidPeriod
+	}
+
+	days := n
+	switch period[len(period)-1] {
+	case 'd':
+	case 'w':
+		days = n * 7
+	default:
+		return 0, ErrInvalidPeriod
+	}
+	if days > maxDays {
+		return 0, ErrInvalidPeriod
+	}
+	return days, nil
+}
+
+// Discoveries returns up to limit artists whose first scrobble falls in the last days days,
+// most played since their discovery first
+func (s *Service) Discoveries(ctx context.Context, days, limit int) (*Discoveries, error) {
+	found, err := s.discoveries(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cached value is shared, so trim a copy
+	trimmed := *found
+	if len(trimmed.Artists) > limit {
+		trimmed.Artists = trimmed.Artists[:limit]
+	}
+	return &trimmed, nil
+}
+
+func (s *Service) discoveries(ctx context.Context, days int) (*Discoveries, error) {
+	return s.results.Get(ctx, days, func(ctx context.Context) (*Discoveries, error) {
+		return s.compute(ctx, days)
+	})
+}
+
+func (s *Service) compute(ctx context.Context, days int) (*Discoveries, error) {
+	now := time.Now().UTC()
+	found := &Discoveries{
+		Period: strconv.Itoa(days) + "d",
+		From:   now.AddDate(0, 0, -days),
+	}
+
+	if s.archive != nil && s.archive.Enabled() && s.archive.Complete() {
+		artists, err := s.archive.Discoveries(found.From)
+		if err != nil {
+			return nil, err
+		}
+		found.Source = "archive"
+		found.Artists = artists
+		return found, nil
+	}
+
+	artists, err := s.fromLastFM(ctx, found.From, now)
+	if err != nil {
+		return nil, err
+	}
+	found.Source = "lastfm"
+	found.Artists = artists
+	return found, nil
+}
+
+// fromLastFM pages through the window's scrobbles and drops artists heard before it. Earlier
+// history comes from one artist chart spanning every chart week up to the window; the few
+// days between the last of those weeks and the window are paged along with it.
+func (s *Service) fromLastFM(ctx context.Context, from, to time.Time) ([]scrobbles.FirstListen, error) {
+	ranges, err := s.lastfm.GetWeeklyChartList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	pageFrom := from
+	if len(ranges) > 0 {
+		var boundary time.Time
+		for _, r := range ranges {
+			if !r.To.After(from) && r.To.After(boundary) {
+				boundary = r.To
+			}
+		}
+		if boundary.After(ranges[0].From) {
+			before, err := s.lastfm.GetWeeklyArtistChart(ctx, ranges[0].From, boundary)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range before {
+				known[strings.ToLower(e.Name)] = true
+			}
+			pageFrom = boundary
+		}
+	}
+
+	found := make(map[string]*scrobbles.FirstListen)
+	for page := 1; ; page++ {
+		result, err := s.lastfm.GetRecentTracksPage(ctx, page, pageSize, pageFrom, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, scrobble := range result.Scrobbles {
+			key := strings.ToLower(scrobble.Artist)
+			if scrobble.PlayedAt.Before(from) {
+				known[key] = true
+				continue
+			}
+
+			listen, ok := found[key]
+			if !ok {
+				listen = &scrobbles.FirstListen{Artist: scrobble.Artist, FirstListen: scrobble.PlayedAt}
+				found[key] = listen
+			}
+			listen.PlayCount++
+			// Pages run newest first, so the earliest play seen is the discovery
+			if scrobble.PlayedAt.Before(listen.FirstListen) {
+				listen.FirstListen = scrobble.PlayedAt
+			}
+		}
+
+		if page >= result.TotalPages || len(result.Scrobbles) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pageDelay):
+		}
+	}
+
+	artists := make([]scrobbles.FirstListen, 0, len(found))
+	for key, listen := range found {
+		if !known[key] {
+			artists = append(artists, *listen)
+		}
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if artists[i].PlayCount != artists[j].PlayCount {
+			return artists[i].PlayCount > artists[j].PlayCount
+		}
+		return artists[i].FirstListen.After(artists[j].FirstListen)
+	})
+	return artists, nil
+}
@@ -0,0 +1,200 @@
+package durations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"tringldev-server/internal/lastfm"
+	"tringldev-server/internal/memo"
+)
+
+const (
+	// A track's length practically never changes once Last.fm knows it
+	durationTTL = 180 * 24 * time.Hour
+
+	// Tracks Last.fm has no length for are asked about again sooner, in case one gets added
+	unknownTTL = 30 * 24 * time.Hour
+
+	// Most played tracks whose lengths are looked up per period, the rest are extrapolated
+	sampleTracks = 200
+
+	// track.getInfo requests in flight at once; a period seen for the first time needs
+	// up to sampleTracks of them
+	fetchConcurrency = 4
+
+	// Listening time is recomputed this often per period
+	resultTTL = time.Hour
+
+	// Upper bound on one estimate, the first for a period may look up hundreds of tracks
+	computeTimeout = 5 * time.Minute
+
+	// Assumed track length when none of the sampled tracks has a known one
+	fallbackTrackLength = 210 * time.Second
+)
+
+// ListeningTime estimates how long the user spent listening over a period
+type ListeningTime struct {
+	Period string `json:"period"`
+	// Start of the period, nil for overall
+	From      *time.Time `json:"from"`
+	Scrobbles int        `json:"scrobbles"`
+	// Plays of tracks with a known length, the others are counted at the average length
+	MeasuredScrobbles   int     `json:"measuredScrobbles"`
+	AverageTrackSeconds int     `json:"averageTrackSeconds"`
+	Hours               float64 `json:"hours"`
+}
+
+// Service looks up track lengths, caching them in SQLite, and turns them into listening time
+type Service struct {
+	db      *sql.DB
+	lastfm  *lastfm.Service
+	results *memo.Cache[string, *ListeningTime]
+}
+
+func NewService(db *sql.DB, service *lastfm.Service) (*Service, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS track_durations (
+		artist TEXT NOT NULL,
+		track TEXT NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		fetched_at INTEGER NOT NULL,
+		PRIMARY KEY (artist, track)
+	);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create track_durations table: %w", err)
+	}
+
+	return &Service{
+		db:      db,
+		lastfm:  service,
+		results: memo.New[string, *ListeningTime](resultTTL, computeTimeout),
+	}, nil
+}
+
+// Duration returns a track's length, 0 when Last.fm doesn't know it. A cached length is used
+// when it is fresh, or when refreshing it fails.
+func (s *Service) Duration(ctx context.Context, artist, track string) (time.Duration, error) {
+	artistKey, trackKey := strings.ToLower(artist), strings.ToLower(track)
+
+	var ms, fetchedAt int64
+	err := s.db.QueryRow("SELECT duration_ms, fetched_at FROM track_durations WHERE artist = ? AND track = ?",
+		artistKey, trackKey).Scan(&ms, &fetchedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to read cached duration: %w", err)
+	}
+	cached := err == nil
+
+	ttl := durationTTL
+	if ms == 0 {
+		ttl = unknownTTL
+	}
+	if cached && time.Since(time.Unix(fetchedAt, 0)) < ttl {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	duration, err := s.lastfm.GetTrackDuration(ctx, artist, track)
+	if errors.Is(err, lastfm.ErrNotFound) {
+		// Stored as a 0 length and asked about again after unknownTTL, Last.fm may learn it later
+		duration, err = 0, nil
+	}
+	if err != nil {
+		if cached {
+			return time.Duration(ms) * time.Millisecond, nil
+		}
+		return 0, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO track_durations (artist, track, duration_ms, fetched_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(artist, track) DO UPDATE SET duration_ms = excluded.duration_ms, fetched_at = excluded.fetched_at`,
+		artistKey, trackKey, duration.Milliseconds(), time.Now().Unix())
+	if err != nil {
+		log.Printf("Failed to cache duration for %s - %s: %v\n", artist, track, err)
+	}
+
+	return duration, nil
+}
+
+// ListeningTime estimates listening time over period (7day to 12month, or overall). The
+// period's most played tracks are measured with their real lengths and the remaining plays
+// are counted at those tracks' average length.
+func (s *Service) ListeningTime(ctx context.Context, period string) (*ListeningTime, error) {
+	return s.results.Get(ctx, period, func(ctx context.Context) (*ListeningTime, error) {
+		return s.compute(ctx, period)
+	})
+}
+
+func (s *Service) compute(ctx context.Context, period string) (*ListeningTime, error) {
+	listening := &ListeningTime{Period: period}
+
+	var from time.Time
+	if start, ok := lastfm.PeriodStart(period, time.Now().UTC()); ok {
+		from = start
+		listening.From = &start
+	}
+
+	total, err := s.lastfm.CountScrobbles(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	listening.Scrobbles = total
+
+	tracks, err := s.lastfm.GetTopTrackPlays(ctx, period, sampleTracks)
+	if err != nil {
+		return nil, err
+	}
+
+	var measured time.Duration
+	for i, duration := range s.durationsFor(ctx, tracks) {
+		if duration > 0 {
+			measured += duration * time.Duration(tracks[i].PlayCount)
+			listening.MeasuredScrobbles += tracks[i].PlayCount
+		}
+	}
+
+	average := fallbackTrackLength
+	if listening.MeasuredScrobbles > 0 {
+		average = measured / time.Duration(listening.MeasuredScrobbles)
+	}
+	// Top track plays can run ahead of the scrobble count when Last.fm's totals lag behind
+	unmeasured := max(listening.Scrobbles-listening.MeasuredScrobbles, 0)
+	estimate := measured + average*time.Duration(unmeasured)
+
+	listening.AverageTrackSeconds = int(average.Seconds())
+	listening.Hours = math.Round(estimate.Hours()*10) / 10
+	return listening, nil
+}
+
+// durationsFor looks up every track's length with bounded concurrency. Tracks whose length
+// can't be fetched count as unknown rather than failing the whole estimate.
+func (s *Service) durationsFor(ctx context.Context, tracks []lastfm.ChartEntry) []time.Duration {
+	results := make([]time.Duration, len(tracks))
+	sem := make(chan struct{}, fetchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, track := range tracks {
+		wg.Add(1)
+		go func(i int, artist, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			duration, err := s.Duration(ctx, artist, name)
+			if err != nil {
+				log.Printf("Error fetching duration for %s - %s: %v\n", artist, name, err)
+				return
+			}
+			results[i] = duration
+		}(i, track.Artist, track.Name)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,251 @@
+package durations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+
+	_ "modernc.org/sqlite"
+)
+
+// track is one of the fake user's top tracks. A negative length makes track.getinfo answer
+// "track not found", and failing makes it fail.
+type track struct {
+	artist, name string
+	plays        int
+	lengthMS     int
+	failing      bool
+}
+
+// fakeLastFM answers the calls ListeningTime makes from a fixed profile and counts them
+type fakeLastFM struct {
+	scrobbles int
+	tracks    []track
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *fakeLastFM) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *fakeLastFM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.mu.Lock()
+	f.calls[query.Get("method")]++
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch query.Get("method") {
+	case "user.getinfo":
+		json.NewEncoder(w).Encode(map[string]any{"user": map[string]any{
+			"name":       "tringl",
+			"playcount":  strconv.Itoa(f.scrobbles),
+			"registered": map[string]string{"unixtime": "1262304000"},
+		}})
+	case "user.getrecenttracks":
+		json.NewEncoder(w).Encode(map[string]any{"recenttracks": map[string]any{
+			"track": []any{},
+			"@attr": map[string]string{"page": "1", "totalPages": "1", "total": strconv.Itoa(f.scrobbles)},
+		}})
+	case "user.gettoptracks":
+		tracks := make([]map[string]any, 0, len(f.tracks))
+		for _, t := range f.tracks {
+			tracks = append(tracks, map[string]any{
+				"name":      t.name,
+				"playcount": strconv.Itoa(t.plays),
+				"artist":    map[string]string{"name": t.artist},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"toptracks": map[string]any{"track": tracks}})
+	case "track.getinfo":
+		for _, t := range f.tracks {
+			if t.artist != query.Get("artist") || t.name != query.Get("track") {
+				continue
+			}
+			switch {
+			case t.failing:
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]any{"error": 8, "message": "Operation failed"})
+			case t.lengthMS < 0:
+				json.NewEncoder(w).Encode(map[string]any{"error": 6, "message": "Track not found"})
+			default:
+				json.NewEncoder(w).Encode(map[string]any{"track": map[string]any{"name": t.name, "duration": strconv.Itoa(t.lengthMS)}})
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"error": 6, "message": "Track not found"})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": 3, "message": "Invalid method"})
+	}
+}
+
+// newTestService points a Service with an in-memory database at fake
+func newTestService(t *testing.T, fake *fakeLastFM) *Service {
+	t.Helper()
+
+	fake.calls = make(map[string]int)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{LastFMUsername: "tringl", LastFMAPIKey: "test-key"}
+	client := lastfm.NewClient(server.URL+"/2.0/", cfg.LastFMAPIKey, server.Client())
+	client.MaxRetries = 0
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	s, err := NewService(db, lastfm.NewServiceWithClient(cfg, client))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+func TestListeningTimePartiallyKnown(t *testing.T) {
+	fake := &fakeLastFM{
+		scrobbles: 100,
+		tracks: []track{
+			{artist: "Burial", name: "Archangel", plays: 10, lengthMS: 200000},
+			{artist: "Four Tet", name: "Baby", plays: 20, lengthMS: 100000},
+			{artist: "Burial", name: "Untitled", plays: 5, lengthMS: 0},
+			{artist: "Nobody", name: "Gone", plays: 5, lengthMS: -1},
+			{artist: "Flaky", name: "Timeout", plays: 5, failing: true},
+		},
+	}
+	s := newTestService(t, fake)
+
+	listening, err := s.ListeningTime(context.Background(), "7day")
+	if err != nil {
+		t.Fatalf("ListeningTime: %v", err)
+	}
+
+	// 4000s measured over 30 plays, the other 70 plays at the 133.3s average
+	if listening.Scrobbles != 100 || listening.MeasuredScrobbles != 30 {
+		t.Errorf("scrobbles = %d, measured = %d, want 100 and 30", listening.Scrobbles, listening.MeasuredScrobbles)
+	}
+	if listening.AverageTrackSeconds != 133 || listening.Hours != 3.7 {
+		t.Errorf("average = %ds, hours = %v, want 133s and 3.7", listening.AverageTrackSeconds, listening.Hours)
+	}
+	if listening.From == nil || time.Since(*listening.From) < 7*24*time.Hour-time.Minute {
+		t.Errorf("From = %v, want a week ago", listening.From)
+	}
+
+	// The result is reused within the hour
+	if _, err := s.ListeningTime(context.Background(), "7day"); err != nil {
+		t.Fatalf("ListeningTime again: %v", err)
+	}
+	if n := fake.count("user.gettoptracks"); n != 1 {
+		t.Errorf("user.gettoptracks called %d times, want 1", n)
+	}
+
+	// Unknown and missing lengths are remembered as 0, failed lookups are not stored
+	var stored, unknown int
+	s.db.QueryRow("SELECT COUNT(*), SUM(duration_ms = 0) FROM track_durations").Scan(&stored, &unknown)
+	if stored != 4 || unknown != 2 {
+		t.Errorf("stored %d durations, %d unknown, want 4 and 2", stored, unknown)
+	}
+}
+
+func TestListeningTimeAllUnknown(t *testing.T) {
+	fake := &fakeLastFM{
+		scrobbles: 120,
+		tracks: []track{
+			{artist: "Burial", name: "Untitled", plays: 70, lengthMS: 0},
+			{artist: "Nobody", name: "Gone", plays: 50, lengthMS: -1},
+		},
+	}
+	s := newTestService(t, fake)
+
+	listening, err := s.ListeningTime(context.Background(), "overall")
+	if err != nil {
+		t.Fatalf("ListeningTime: %v", err)
+	}
+
+	// Every play is counted at the fallback length
+	if listening.From != nil || listening.Scrobbles != 120 || listening.MeasuredScrobbles != 0 {
+		t.Errorf("unexpected listening time: %+v", listening)
+	}
+	if listening.AverageTrackSeconds != 210 || listening.Hours != 7 {
+		t.Errorf("average = %ds, hours = %v, want 210s and 7", listening.AverageTrackSeconds, listening.Hours)
+	}
+	if fake.count("user.getinfo") != 1 || fake.count("user.getrecenttracks") != 0 {
+		t.Errorf("overall should count scrobbles from the profile: %v", fake.calls)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	fake := &fakeLastFM{
+		tracks: []track{
+			{artist: "Burial", name: "Archangel", lengthMS: 239000},
+			{artist: "Burial", name: "Untitled", lengthMS: 0},
+			{artist: "Flaky", name: "Timeout", failing: true},
+			{artist: "Flaky", name: "Never Cached", failing: true},
+		},
+	}
+	s := newTestService(t, fake)
+
+	cache := func(artist, track string, ms int64, age time.Duration) {
+		t.Helper()
+		_, err := s.db.Exec("INSERT INTO track_durations (artist, track, duration_ms, fetched_at) VALUES (?, ?, ?, ?)",
+			artist, track, ms, time.Now().Add(-age).Unix())
+		if err != nil {
+			t.Fatalf("seeding cache: %v", err)
+		}
+	}
+	// Fresh, so Last.fm isn't asked even though it now knows a different length
+	cache("burial", "archangel", 200000, 24*time.Hour)
+	// Unknown lengths expire sooner than known ones
+	cache("burial", "untitled", 0, unknownTTL+time.Hour)
+	// Expired, and refreshing it fails
+	cache("flaky", "timeout", 180000, durationTTL+time.Hour)
+
+	tests := []struct {
+		artist, track string
+		want          time.Duration
+		wantErr       bool
+	}{
+		{"BURIAL", "Archangel", 200 * time.Second, false},
+		{"Burial", "Untitled", 0, false},
+		{"Flaky", "Timeout", 180 * time.Second, false},
+		{"Flaky", "Never Cached", 0, true},
+		{"Nobody", "Gone", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := s.Duration(context.Background(), tt.artist, tt.track)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Duration(%s, %s) = %v, %v; want %v, error %v", tt.artist, tt.track, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// Archangel was served from the cache; the rest went to Last.fm
+	if n := fake.count("track.getinfo"); n != 4 {
+		t.Errorf("track.getinfo called %d times, want 4", n)
+	}
+
+	// A track not found is remembered, so asking again doesn't hit Last.fm
+	if _, err := s.Duration(context.Background(), "Nobody", "Gone"); err != nil {
+		t.Fatalf("Duration: %v", err)
+	}
+	if n := fake.count("track.getinfo"); n != 4 {
+		t.Errorf("track.getinfo called %d times after a cached miss, want 4", n)
+	}
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/lastfm"
+)
+
+const (
+	// Scrobbles per user.getrecenttracks page, Last.fm's maximum
+	pageSize = 200
+
+	// Pause between pages, a long history is hundreds of them
+	pageDelay = 250 * time.Millisecond
+
+	// Exports running at once, each one keeps paging Last.fm until it is done
+	maxConcurrent = 2
+)
+
+var (
+	ErrInvalidFormat = errors.New("format must be csv or ndjson")
+	// Too many exports are already running
+	ErrBusy = errors.New("too many exports in progress, try again later")
+)
+
+// Formats maps each export format to its content type
+var Formats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// Exporter streams scrobble history out of Last.fm page by page, so memory use stays flat
+// however long the history is
+type Exporter struct {
+	lastfm *lastfm.Service
+	slots  chan struct{}
+}
+
+func NewExporter(service *lastfm.Service) *Exporter {
+	return &Exporter{
+		lastfm: service,
+		slots:  make(chan struct{}, maxConcurrent),
+	}
+}
+
+// rowWriter writes scrobbles in one of the export formats
+type rowWriter interface {
+	begin() error
+	write(s lastfm.Scrobble) error
+	flush() error
+}
+
+// Export writes the scrobbles between from and to (open ends allowed), newest first, to w as
+// each page arrives. Nothing is written until the first page has been fetched, so when
+// written is 0 the caller can still answer with an error status.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, format string, from, to time.Time) (written int, err error) {
+	var rows rowWriter
+	switch format {
+	case "csv":
+		rows = &csvRows{w: csv.NewWriter(w)}
+	case "ndjson":
+		rows = &ndjsonRows{enc: json.NewEncoder(w)}
+	default:
+		return 0, ErrInvalidFormat
+	}
+
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	default:
+		return 0, ErrBusy
+	}
+
+	// A fixed end keeps page boundaries still while new scrobbles come in
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for page := 1; ; page++ {
+		result, err := e.lastfm.GetRecentTracksPage(ctx, page, pageSize, from, to)
+		if err != nil {
+			return written, err
+		}
+
+		if page == 1 {
+			if err := rows.begin(); err != nil {
+				return written, err
+			}
+		}
+		for _, s := range result.Scrobbles {
+			if err := rows.write(s); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if err := rows.flush(); err != nil {
+			return written, err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if page >= result.TotalPages || len(result.Scrobbles) == 0 {
+			return written, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return written, ctx.Err()
+		case <-time.After(pageDelay):
+		}
+	}
+}
+
+type csvRows struct {
+	w *csv.Writer
+}
+
+func (c *csvRows) begin() error {
+	return c.w.Write([]string{"played_at", "timestamp", "artist", "track", "album", "url"})
+}
+
+func (c *csvRows) write(s lastfm.Scrobble) error {
+	return c.w.Write([]string{
+		s.PlayedAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(s.PlayedAt.Unix(), 10),
+		spreadsheetSafe(s.Artist),
+		spreadsheetSafe(s.Track),
+		spreadsheetSafe(s.Album),
+		spreadsheetSafe(s.URL),
+	})
+}
+
+// spreadsheetSafe stops a spreadsheet from reading a cell as a formula, e.g. a track called
+// =HYPERLINK(...), by prefixing it with a quote
+func spreadsheetSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (c *csvRows) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonRows struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonRows) begin() error {
+	return nil
+}
+
+// write encodes one scrobble per line, Encoder adds the newline
+func (n *ndjsonRows) write(s lastfm.Scrobble) error {
+	return n.enc.Encode(s)
+}
+
+func (n *ndjsonRows) flush() error {
+	return nil
+}
@@ -0,0 +1,171 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+)
+
+// fakeHistory serves user.getrecenttracks from fixed pages and records which were asked for.
+// Pages past the end are still served, so only TotalPages stops the export.
+type fakeHistory struct {
+	pages   [][]lastfm.Scrobble
+	failing bool
+
+	mu        sync.Mutex
+	requested []int
+}
+
+func (f *fakeHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	f.mu.Lock()
+	f.requested = append(f.requested, page)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if f.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": 8, "message": "Operation failed"})
+		return
+	}
+
+	scrobbles := f.pages[0]
+	if page <= len(f.pages) {
+		scrobbles = f.pages[page-1]
+	}
+	tracks := make([]map[string]any, 0, len(scrobbles))
+	for _, s := range scrobbles {
+		tracks = append(tracks, map[string]any{
+			"name":   s.Track,
+			"artist": map[string]string{"#text": s.Artist},
+			"album":  map[string]string{"#text": s.Album},
+			"url":    s.URL,
+			"date":   map[string]string{"uts": strconv.FormatInt(s.PlayedAt.Unix(), 10)},
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"recenttracks": map[string]any{
+		"track": tracks,
+		"@attr": map[string]string{"page": strconv.Itoa(page), "totalPages": strconv.Itoa(len(f.pages))},
+	}})
+}
+
+func newTestExporter(t *testing.T, fake *fakeHistory) *Exporter {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{LastFMUsername: "tringl", LastFMAPIKey: "test-key"}
+	client := lastfm.NewClient(server.URL+"/2.0/", cfg.LastFMAPIKey, server.Client())
+	client.MaxRetries = 0
+	return NewExporter(lastfm.NewServiceWithClient(cfg, client))
+}
+
+func scrobble(artist, track string, unix int64) lastfm.Scrobble {
+	return lastfm.Scrobble{
+		Artist:   artist,
+		Track:    track,
+		Album:    "Album",
+		URL:      "https://www.last.fm/music/" + artist,
+		PlayedAt: time.Unix(unix, 0).UTC(),
+	}
+}
+
+var history = [][]lastfm.Scrobble{
+	{scrobble("Burial", "Archangel", 1759752000), scrobble("=cmd", "+1", 1759751000)},
+	{scrobble("-Four Tet", "@home", 1759750000)},
+	{scrobble("Aphex Twin", "Xtal", 1759749000)},
+}
+
+func TestExportCSV(t *testing.T) {
+	fake := &fakeHistory{pages: history}
+	var out strings.Builder
+	written, err := newTestExporter(t, fake).Export(context.Background(), &out, "csv", time.Time{}, time.Time{})
+	if err != nil || written != 4 {
+		t.Fatalf("Export = %d, %v; want 4 rows", written, err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	want := [][]string{
+		{"played_at", "timestamp", "artist", "track", "album", "url"},
+		{"2025-10-06T12:00:00Z", "1759752000", "Burial", "Archangel", "Album", "https://www.last.fm/music/Burial"},
+		// Cells a spreadsheet would run as formulas are quoted
+		{"2025-10-06T11:43:20Z", "1759751000", "'=cmd", "'+1", "Album", "https://www.last.fm/music/=cmd"},
+		{"2025-10-06T11:26:40Z", "1759750000", "'-Four Tet", "'@home", "Album", "https://www.last.fm/music/-Four Tet"},
+		{"2025-10-06T11:10:00Z", "1759749000", "Aphex Twin", "Xtal", "Album", "https://www.last.fm/music/Aphex Twin"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d:\n%s", len(records), len(want), out.String())
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	fake := &fakeHistory{pages: history[:2]}
+	var out strings.Builder
+	written, err := newTestExporter(t, fake).Export(context.Background(), &out, "ndjson", time.Time{}, time.Time{})
+	if err != nil || written != 3 {
+		t.Fatalf("Export = %d, %v; want 3 rows", written, err)
+	}
+
+	// One object per line, newest first, and left as Last.fm has it
+	var got []lastfm.Scrobble
+	lines := bufio.NewScanner(strings.NewReader(out.String()))
+	for lines.Scan() {
+		var s lastfm.Scrobble
+		if err := json.Unmarshal(lines.Bytes(), &s); err != nil {
+			t.Fatalf("line %q: %v", lines.Text(), err)
+		}
+		got = append(got, s)
+	}
+	want := append(append([]lastfm.Scrobble{}, history[0]...), history[1]...)
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExportStopsAtTotalPages(t *testing.T) {
+	fake := &fakeHistory{pages: history}
+	if _, err := newTestExporter(t, fake).Export(context.Background(), &strings.Builder{}, "ndjson", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if got := fake.requested; len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Errorf("requested pages %v, want 1 to 3", got)
+	}
+}
+
+func TestExportWritesNothingOnFirstPageError(t *testing.T) {
+	fake := &fakeHistory{pages: history, failing: true}
+	var out strings.Builder
+	written, err := newTestExporter(t, fake).Export(context.Background(), &out, "csv", time.Time{}, time.Time{})
+	if err == nil || written != 0 || out.Len() != 0 {
+		t.Errorf("Export = %d, %v with %q written; want an error and no output", written, err, out.String())
+	}
+
+	if _, err := newTestExporter(t, fake).Export(context.Background(), &out, "xml", time.Time{}, time.Time{}); err != ErrInvalidFormat {
+		t.Errorf("unknown format error = %v, want ErrInvalidFormat", err)
+	}
+}
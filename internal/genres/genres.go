@@ -0,0 +1,270 @@
+package genres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+)
+
+const (
+	// Tags on established artists barely move, so they're only refetched monthly
+	tagTTL = 30 * 24 * time.Hour
+
+	// Tags kept per artist, the long tail is mostly noise
+	tagsPerArtist = 5
+
+	// Tags weighted below this (out of 100) are dropped unless nothing else is left
+	minTagCount = 10
+
+	// Parallel artist.getTopTags requests when many artists miss the cache at once
+	fetchConcurrency = 4
+)
+
+type Genre struct {
+	Name string `json:"name"`
+	// Fraction of the period's plays attributed to this genre
+	Share float64 `json:"share"`
+	// Up to three of the artists contributing most to this genre
+	TopArtists []string `json:"topArtists"`
+}
+
+// Service looks up artist tags, caching them in SQLite, and turns them into genre breakdowns
+type Service struct {
+	config *config.Config
+	db     *sql.DB
+	lastfm *lastfm.Service
+	deny   map[string]bool
+}
+
+func NewService(cfg *config.Config, db *sql.DB, service *lastfm.Service) (*Service, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS artist_tags (
+		artist TEXT PRIMARY KEY,
+		tags TEXT NOT NULL,
+		fetched_at INTEGER NOT NULL
+	);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create artist_tags table: %w", err)
+	}
+
+	deny := make(map[string]bool, len(cfg.GenreDenylist))
+	for _, tag := range cfg.GenreDenylist {
+		deny[normalize(tag)] = true
+	}
+
+	return &Service{
+		config: cfg,
+		db:     db,
+		lastfm: service,
+		deny:   deny,
+	}, nil
+}
+
+// ArtistTags returns an artist's cleaned up tags, heaviest first. A cached copy is used when
+// it is fresh, or when refreshing it fails.
+func (s *Service) ArtistTags(ctx context.Context, artist string) ([]lastfm.Tag, error) {
+	key := strings.ToLower(artist)
+
+	var raw string
+	var fetchedAt int64
+	err := s.db.QueryRow("SELECT tags, fetched_at FROM artist_tags WHERE artist = ?", key).Scan(&raw, &fetchedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read cached tags: %w", err)
+	}
+	cached := err == nil
+
+	if cached && time.Since(time.Unix(fetchedAt, 0)) < tagTTL {
+		return s.decode(artist, raw)
+	}
+
+	tags, err := s.lastfm.GetArtistTopTags(ctx, artist)
+	if errors.Is(err, lastfm.ErrNotFound) {
+		// Remember unknown artists too, so they aren't looked up on every request
+		tags, err = []lastfm.Tag{}, nil
+	}
+	if err != nil {
+		if cached {
+			return s.decode(artist, raw)
+		}
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO artist_tags (artist, tags, fetched_at) VALUES (?, ?, ?)
+		ON CONFLICT(artist) DO UPDATE SET tags = excluded.tags, fetched_at = excluded.fetched_at`,
+		key, string(encoded), time.Now().Unix())
+	if err != nil {
+		log.Printf("Failed to cache tags for %s: %v\n", artist, err)
+	}
+
+	return s.clean(artist, tags), nil
+}
+
+// decode reads the raw tags stored for an artist. The denylist is applied on the way out so
+// changing it takes effect without refetching.
+func (s *Service) decode(artist, raw string) ([]lastfm.Tag, error) {
+	var tags []lastfm.Tag
+	if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+		return nil, fmt.Errorf("failed to decode cached tags: %w", err)
+	}
+	return s.clean(artist, tags), nil
+}
+
+// clean normalises tag names, drops denylisted tags, duplicates and the artist's own name,
+// and keeps the heaviest tagsPerArtist
+func (s *Service) clean(artist string, tags []lastfm.Tag) []lastfm.Tag {
+	self := normalize(artist)
+	seen := make(map[string]bool)
+
+	var kept, weak []lastfm.Tag
+	for _, tag := range tags {
+		name := normalize(tag.Name)
+		if name == "" || name == self || s.deny[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if tag.Count < minTagCount {
+			weak = append(weak, lastfm.Tag{Name: name, Count: tag.Count})
+			continue
+		}
+		kept = append(kept, lastfm.Tag{Name: name, Count: tag.Count})
+	}
+
+	if len(kept) == 0 {
+		kept = weak
+	}
+	if len(kept) > tagsPerArtist {
+		kept = kept[:tagsPerArtist]
+	}
+	return kept
+}
+
+// normalize lowercases a tag and treats "hip-hop" and "hip hop" as the same genre
+func normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return strings.Join(strings.FieldsFunc(tag, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), " ")
+}
+
+// tagsFor looks up tags for every artist with bounded concurrency. Artists whose tags can't be
+// fetched are left out rather than failing the whole request.
+func (s *Service) tagsFor(ctx context.Context, artists []lastfm.TopArtist) [][]lastfm.Tag {
+	results := make([][]lastfm.Tag, len(artists))
+	// Tags come from Last.fm even with another music provider, and need its API key
+	if s.config.LastFMAPIKey == "" {
+		return results
+	}
+	sem := make(chan struct{}, fetchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, artist := range artists {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			tags, err := s.ArtistTags(ctx, name)
+			if err != nil {
+				log.Printf("Error fetching tags for %s: %v\n", name, err)
+				return
+			}
+			results[i] = tags
+		}(i, artist.Name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Enrich returns a copy of artists with their tags filled in. The input is left untouched
+// since it usually comes from the shared Last.fm cache.
+func (s *Service) Enrich(ctx context.Context, artists []lastfm.TopArtist) []lastfm.TopArtist {
+	enriched := make([]lastfm.TopArtist, len(artists))
+	for i, tags := range s.tagsFor(ctx, artists) {
+		enriched[i] = artists[i]
+		enriched[i].Tags = make([]string, 0, len(tags))
+		for _, tag := range tags {
+			enriched[i].Tags = append(enriched[i].Tags, tag.Name)
+		}
+	}
+	return enriched
+}
+
+// Genres splits each artist's playcount across its tags in proportion to their weights and
+// returns the limit genres with the largest share of the total
+func (s *Service) Genres(ctx context.Context, artists []lastfm.TopArtist, limit int) []Genre {
+	type contribution struct {
+		artist string
+		score  float64
+	}
+
+	scores := make(map[string]float64)
+	contributors := make(map[string][]contribution)
+	total := 0.0
+
+	for i, tags := range s.tagsFor(ctx, artists) {
+		plays, _ := strconv.ParseFloat(artists[i].PlayCount, 64)
+		weightSum := 0
+		for _, tag := range tags {
+			weightSum += tag.Count
+		}
+		if plays == 0 || weightSum == 0 {
+			continue
+		}
+
+		for _, tag := range tags {
+			score := plays * float64(tag.Count) / float64(weightSum)
+			scores[tag.Name] += score
+			contributors[tag.Name] = append(contributors[tag.Name], contribution{artists[i].Name, score})
+			total += score
+		}
+	}
+
+	genres := make([]Genre, 0, len(scores))
+	for name, score := range scores {
+		contribs := contributors[name]
+		sort.Slice(contribs, func(a, b int) bool { return contribs[a].score > contribs[b].score })
+
+		top := make([]string, 0, 3)
+		for _, c := range contribs[:min(len(contribs), 3)] {
+			top = append(top, c.artist)
+		}
+
+		genres = append(genres, Genre{
+			Name:       name,
+			Share:      math.Round(score/total*10000) / 10000,
+			TopArtists: top,
+		})
+	}
+
+	sort.Slice(genres, func(a, b int) bool {
+		if genres[a].Share != genres[b].Share {
+			return genres[a].Share > genres[b].Share
+		}
+		return genres[a].Name < genres[b].Name
+	})
+
+	if len(genres) > limit {
+		genres = genres[:limit]
+	}
+	return genres
+}
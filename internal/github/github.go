@@ -1,6 +1,7 @@
 package github
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,11 @@ import (
 	"tringldev-server/internal/config"
 )
 
+const graphQLURL = "https://api.github.com/graphql"
+
+// Most repositories a GitHub profile can pin
+const maxPinned = 6
+
 type Service struct {
 	config *config.Config
 }
@@ -46,7 +52,153 @@ func NewService(cfg *config.Config) *Service {
 	}
 }
 
+// GetPinnedRepository returns the first repository pinned on the profile. Without a token
+// GitHub's GraphQL API can't be used, so the most recently updated repository stands in.
 func (s *Service) GetPinnedRepository() (*PinnedRepo, error) {
+	if s.config.GithubToken == "" {
+		return s.getMostRecentRepository()
+	}
+
+	repos, err := s.GetPinnedRepositories()
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no pinned repositories found")
+	}
+	return repos[0], nil
+}
+
+const pinnedQuery = `query($login: String!, $first: Int!) {
+  user(login: $login) {
+    pinnedItems(first: $first, types: REPOSITORY) {
+      nodes {
+        ... on Repository {
+          name
+          nameWithOwner
+          description
+          url
+          homepageUrl
+          stargazerCount
+          forkCount
+          updatedAt
+          primaryLanguage { name }
+          repositoryTopics(first: 20) { nodes { topic { name } } }
+        }
+      }
+    }
+  }
+}`
+
+type pinnedResponse struct {
+	Data struct {
+		User *struct {
+			PinnedItems struct {
+				Nodes []struct {
+					Name            string `json:"name"`
+					NameWithOwner   string `json:"nameWithOwner"`
+					Description     string `json:"description"`
+					URL             string `json:"url"`
+					HomepageURL     string `json:"homepageUrl"`
+					StargazerCount  int    `json:"stargazerCount"`
+					ForkCount       int    `json:"forkCount"`
+					UpdatedAt       string `json:"updatedAt"`
+					PrimaryLanguage *struct {
+						Name string `json:"name"`
+					} `json:"primaryLanguage"`
+					RepositoryTopics struct {
+						Nodes []struct {
+							Topic struct {
+								Name string `json:"name"`
+							} `json:"topic"`
+						} `json:"nodes"`
+					} `json:"repositoryTopics"`
+				} `json:"nodes"`
+			} `json:"pinnedItems"`
+		} `json:"user"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// GetPinnedRepositories returns the repositories pinned on the profile, in the order shown
+// there. GitHub only exposes pins through GraphQL, which needs a token; without one the most
+// recently updated repositories are returned instead.
+func (s *Service) GetPinnedRepositories() ([]*PinnedRepo, error) {
+	if s.config.GithubToken == "" {
+		return s.GetAllPublicRepositories()
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"query":     pinnedQuery,
+		"variables": map[string]any{"login": s.config.GithubUsername, "first": maxPinned},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", graphQLURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.config.GithubToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pinned repositories: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("github API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result pinnedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// GraphQL reports failures such as an unknown user in the body with a 200 status
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("github GraphQL error: %s", result.Errors[0].Message)
+	}
+	if result.Data.User == nil {
+		return nil, fmt.Errorf("github user %s not found", s.config.GithubUsername)
+	}
+
+	nodes := result.Data.User.PinnedItems.Nodes
+	pinnedRepos := make([]*PinnedRepo, 0, len(nodes))
+	for _, node := range nodes {
+		repo := &PinnedRepo{
+			Name:        node.Name,
+			FullName:    node.NameWithOwner,
+			Description: node.Description,
+			URL:         node.URL,
+			Stars:       node.StargazerCount,
+			Forks:       node.ForkCount,
+			Topics:      make([]string, 0, len(node.RepositoryTopics.Nodes)),
+			UpdatedAt:   node.UpdatedAt,
+			Homepage:    node.HomepageURL,
+		}
+		if node.PrimaryLanguage != nil {
+			repo.Language = node.PrimaryLanguage.Name
+		}
+		for _, topic := range node.RepositoryTopics.Nodes {
+			repo.Topics = append(repo.Topics, topic.Topic.Name)
+		}
+		pinnedRepos = append(pinnedRepos, repo)
+	}
+
+	return pinnedRepos, nil
+}
+
+// getMostRecentRepository is the stand-in for a pinned repository when no token is configured
+func (s *Service) getMostRecentRepository() (*PinnedRepo, error) {
 	url := fmt.Sprintf("https://api.github.com/users/%s/repos?sort=updated&per_page=10", s.config.GithubUsername)
 
 	req, err := http.NewRequest("GET", url, nil)
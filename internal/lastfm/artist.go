@@ -0,0 +1,202 @@
+package lastfm
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ArtistDetail is everything /api/artists/{name} shows about an artist
+type ArtistDetail struct {
+	Name string `json:"name"`
+	// Set when Last.fm autocorrected the requested name to Name
+	Corrected bool     `json:"corrected"`
+	URL       string   `json:"url"`
+	Bio       string   `json:"bio"`
+	Tags      []string `json:"tags"`
+	Listeners int      `json:"listeners"`
+	PlayCount int      `json:"playcount"`
+	// Plays by the configured user
+	UserPlayCount int             `json:"userPlaycount"`
+	Similar       []SimilarArtist `json:"similar"`
+	// The configured user's most played tracks by the artist
+	TopTracks []ChartEntry `json:"topTracks"`
+}
+
+type SimilarArtist struct {
+	Name string `json:"name"`
+	// Similarity from 0 to 1
+	Match float64 `json:"match"`
+	URL   string  `json:"url"`
+}
+
+// Tag is a folksonomy tag with its weight for an artist, from 0 to 100
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type topTagsResponse struct {
+	TopTags struct {
+		Tag oneOrMany[struct {
+			Name string `json:"name"`
+			// A number, but some mirrors of the API quote it
+			Count json.Number `json:"count"`
+		}] `json:"tag"`
+	} `json:"toptags"`
+}
+
+// GetArtistTopTags returns the tags listeners applied most to an artist, heaviest first
+func (s *Service) GetArtistTopTags(ctx context.Context, artist string) ([]Tag, error) {
+	params := url.Values{
+		"artist":      {artist},
+		"autocorrect": {"1"},
+	}
+
+	var lastfmResp topTagsResponse
+	if err := s.client.Call(ctx, "artist.gettoptags", params, &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	tags := make([]Tag, 0, len(lastfmResp.TopTags.Tag))
+	for _, tag := range lastfmResp.TopTags.Tag {
+		count, _ := tag.Count.Int64()
+		tags = append(tags, Tag{Name: tag.Name, Count: int(count)})
+	}
+	return tags, nil
+}
+
+type artistInfoResponse struct {
+	Artist struct {
+		Name  string `json:"name"`
+		URL   string `json:"url"`
+		Stats struct {
+			Listeners     string `json:"listeners"`
+			PlayCount     string `json:"playcount"`
+			UserPlayCount string `json:"userplaycount"`
+		} `json:"stats"`
+		Tags struct {
+			Tag oneOrMany[struct {
+				Name string `json:"name"`
+			}] `json:"tag"`
+		} `json:"tags"`
+		Bio struct {
+			Summary string `json:"summary"`
+		} `json:"bio"`
+	} `json:"artist"`
+}
+
+type similarArtistsResponse struct {
+	SimilarArtists struct {
+		Artist oneOrMany[struct {
+			Name  string `json:"name"`
+			Match string `json:"match"`
+			URL   string `json:"url"`
+		}] `json:"artist"`
+	} `json:"similarartists"`
+}
+
+const (
+	// Similar artists and top tracks listed per artist
+	similarArtistsLimit = 10
+	artistTracksLimit   = 10
+
+	// The user's most played tracks searched for an artist's tracks
+	artistTracksSearched = 1000
+)
+
+// GetArtistDetail combines artist.getInfo, artist.getSimilar and the user's top tracks by the
+// artist. Misspelt names are corrected by Last.fm, Corrected tells when that happened.
+func (s *Service) GetArtistDetail(ctx context.Context, name string) (*ArtistDetail, error) {
+	// artist.getInfo takes the user as username, and only then reports their play count
+	params := url.Values{
+		"artist":      {name},
+		"username":    {s.username},
+		"autocorrect": {"1"},
+	}
+
+	var infoResp artistInfoResponse
+	if err := s.client.Call(ctx, "artist.getinfo", params, &infoResp); err != nil {
+		return nil, err
+	}
+	info := infoResp.Artist
+
+	detail := &ArtistDetail{
+		Name:      info.Name,
+		Corrected: !strings.EqualFold(strings.TrimSpace(name), info.Name),
+		URL:       info.URL,
+		Bio:       stripBio(info.Bio.Summary),
+		Tags:      make([]string, 0, len(info.Tags.Tag)),
+		Similar:   make([]SimilarArtist, 0),
+		TopTracks: make([]ChartEntry, 0),
+	}
+	detail.Listeners, _ = strconv.Atoi(info.Stats.Listeners)
+	detail.PlayCount, _ = strconv.Atoi(info.Stats.PlayCount)
+	detail.UserPlayCount, _ = strconv.Atoi(info.Stats.UserPlayCount)
+	for _, tag := range info.Tags.Tag {
+		detail.Tags = append(detail.Tags, tag.Name)
+	}
+
+	similarParams := url.Values{
+		"artist": {detail.Name},
+		"limit":  {strconv.Itoa(similarArtistsLimit)},
+	}
+	var similarResp similarArtistsResponse
+	if err := s.client.Call(ctx, "artist.getsimilar", similarParams, &similarResp); err != nil {
+		return nil, err
+	}
+	for _, artist := range similarResp.SimilarArtists.Artist {
+		match, _ := strconv.ParseFloat(artist.Match, 64)
+		detail.Similar = append(detail.Similar, SimilarArtist{Name: artist.Name, Match: match, URL: artist.URL})
+	}
+
+	// Only worth searching the user's top tracks when they've played the artist at all
+	if detail.UserPlayCount > 0 {
+		tracks, err := s.GetTopTrackPlays(ctx, "overall", artistTracksSearched)
+		if err != nil {
+			return nil, err
+		}
+		for _, track := range tracks {
+			if strings.EqualFold(track.Artist, detail.Name) {
+				detail.TopTracks = append(detail.TopTracks, track)
+				if len(detail.TopTracks) == artistTracksLimit {
+					break
+				}
+			}
+		}
+	}
+
+	return detail, nil
+}
+
+// stripBio turns Last.fm's HTML bio summary into plain text, without the "Read more on
+// Last.fm" link it always ends with
+func stripBio(summary string) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(summary))
+	inLink := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "a" {
+				inLink = true
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "a" {
+				inLink = false
+			}
+		case html.TextToken:
+			text := string(tokenizer.Text())
+			if inLink && strings.TrimSpace(text) == "Read more on Last.fm" {
+				continue
+			}
+			b.WriteString(text)
+		}
+	}
+}
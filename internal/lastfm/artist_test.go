@@ -0,0 +1,104 @@
+package lastfm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetArtistDetail(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"artist.getinfo":    {file: "artistinfo.json"},
+		"artist.getsimilar": {file: "similarartists.json"},
+		"user.gettoptracks": {file: "toptracks.json"},
+	})
+
+	detail, err := service.GetArtistDetail(context.Background(), " burial ")
+	if err != nil {
+		t.Fatalf("GetArtistDetail: %v", err)
+	}
+
+	if detail.Name != "Burial" || detail.Corrected {
+		t.Errorf("Name = %q, Corrected = %v, want Burial uncorrected", detail.Name, detail.Corrected)
+	}
+	if detail.Bio != "Burial is the alias of William Bevan, a producer from south London." {
+		t.Errorf("Bio = %q", detail.Bio)
+	}
+	if detail.Listeners != 1234567 || detail.PlayCount != 98765432 || detail.UserPlayCount != 420 {
+		t.Errorf("unexpected stats: %+v", detail)
+	}
+	if len(detail.Tags) != 2 || detail.Tags[0] != "dubstep" {
+		t.Errorf("Tags = %v", detail.Tags)
+	}
+	if len(detail.Similar) != 2 || detail.Similar[1] != (SimilarArtist{Name: "Zomby", Match: 0.812345, URL: "https://www.last.fm/music/Zomby"}) {
+		t.Errorf("Similar = %+v", detail.Similar)
+	}
+	// Only the user's top tracks by the artist are kept
+	if len(detail.TopTracks) != 2 || detail.TopTracks[0].Name != "Archangel" || detail.TopTracks[1].Name != "Near Dark" {
+		t.Errorf("TopTracks = %+v", detail.TopTracks)
+	}
+
+	info := rec.last(t, "artist.getinfo")
+	if info.Get("username") != "tringl" || info.Get("autocorrect") != "1" || info.Has("user") {
+		t.Errorf("unexpected artist.getinfo query: %v", info)
+	}
+	if got := rec.last(t, "artist.getsimilar").Get("artist"); got != "Burial" {
+		t.Errorf("artist.getsimilar asked for %q, want the corrected name", got)
+	}
+}
+
+func TestGetArtistDetailUnplayed(t *testing.T) {
+	// No user.gettoptracks fixture: the user's tracks must not be searched
+	service, _ := newFixtureServer(t, map[string]fixture{
+		"artist.getinfo":    {file: "artistinfo_unplayed.json"},
+		"artist.getsimilar": {file: "similarartists_empty.json"},
+	})
+
+	detail, err := service.GetArtistDetail(context.Background(), "Burrial")
+	if err != nil {
+		t.Fatalf("GetArtistDetail: %v", err)
+	}
+	if !detail.Corrected || detail.Bio != "" {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+	if len(detail.Tags) != 1 || detail.Tags[0] != "dubstep" {
+		t.Errorf("Tags = %v, want the single tag sent as an object", detail.Tags)
+	}
+	if detail.Similar == nil || len(detail.Similar) != 0 || detail.TopTracks == nil || len(detail.TopTracks) != 0 {
+		t.Errorf("expected empty, non-nil lists: %#v, %#v", detail.Similar, detail.TopTracks)
+	}
+}
+
+func TestGetArtistTopTags(t *testing.T) {
+	service, _ := newFixtureServer(t, map[string]fixture{
+		"artist.gettoptags": {file: "toptags.json"},
+	})
+
+	tags, err := service.GetArtistTopTags(context.Background(), "Burial")
+	if err != nil {
+		t.Fatalf("GetArtistTopTags: %v", err)
+	}
+	// The second count is quoted in the fixture
+	want := []Tag{{Name: "dubstep", Count: 100}, {Name: "electronic", Count: 57}}
+	if len(tags) != len(want) || tags[0] != want[0] || tags[1] != want[1] {
+		t.Errorf("tags = %+v, want %+v", tags, want)
+	}
+}
+
+func TestStripBio(t *testing.T) {
+	tests := []struct {
+		summary string
+		want    string
+	}{
+		{"", ""},
+		{"Plain text", "Plain text"},
+		{`<b>Bold</b> and   spaced <a href="https://www.last.fm/music/X">Read more on Last.fm</a>`, "Bold and spaced"},
+		{`See <a href="https://example.com">their site</a>.`, "See their site."},
+		{"Rock &amp; roll", "Rock & roll"},
+	}
+
+	for _, tt := range tests {
+		if got := stripBio(tt.summary); got != tt.want {
+			t.Errorf("stripBio(%q) = %q, want %q", tt.summary, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package lastfm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheTTLs controls how long each kind of response is served before Last.fm is asked again
+type CacheTTLs struct {
+	NowPlaying   time.Duration
+	RecentTracks time.Duration
+	TopCharts    time.Duration
+	Stats        time.Duration
+	Loved        time.Duration
+	Artist       time.Duration
+	// How long an expired entry may still be served when refreshing it fails
+	MaxStale time.Duration
+}
+
+var DefaultCacheTTLs = CacheTTLs{
+	NowPlaying:   15 * time.Second,
+	RecentTracks: 30 * time.Second,
+	TopCharts:    time.Hour,
+	Stats:        10 * time.Minute,
+	Loved:        10 * time.Minute,
+	Artist:       24 * time.Hour,
+	MaxStale:     24 * time.Hour,
+}
+
+// CacheStatus describes how a cached call was answered, for Cache-Control and Age headers
+type CacheStatus struct {
+	Hit    bool
+	Stale  bool
+	Age    time.Duration
+	MaxAge time.Duration
+	// How long past MaxAge an entry may be served if Last.fm fails
+	StaleIfError time.Duration
+}
+
+type cacheStatusKey struct{}
+
+// WithCacheStatus returns a context that records how the next CachedService call was answered
+func WithCacheStatus(ctx context.Context) (context.Context, *CacheStatus) {
+	status := &CacheStatus{}
+	return context.WithValue(ctx, cacheStatusKey{}, status), status
+}
+
+type cacheEntry struct {
+	value     any
+	fetchedAt time.Time
+	// Past this the entry can't even be served stale and may be dropped
+	expiresAt time.Time
+}
+
+// Upper bound on cached responses per user. Date ranges and artist names come straight from
+// requests, so the key space is otherwise unbounded.
+const maxCacheEntries = 1000
+
+// CachedService wraps a Provider with per-method TTL caching. Concurrent misses for the same
+// key share one upstream request, and stale entries are served when a refresh fails.
+type CachedService struct {
+	service Provider
+	ttls    CacheTTLs
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	group   singleflight.Group
+
+	// The loved set is built in the background, see lovedSet
+	lovedMu       sync.Mutex
+	loved         map[string]bool
+	lovedAt       time.Time
+	lovedFailedAt time.Time
+	lovedBuilding bool
+}
+
+func NewCachedService(service Provider, ttls CacheTTLs) *CachedService {
+	return &CachedService{
+		service: service,
+		ttls:    ttls,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *CachedService) GetCurrentlyPlaying(ctx context.Context) (*NowPlayingInfo, error) {
+	return cached(ctx, c, "nowplaying", c.ttls.NowPlaying, c.service.GetCurrentlyPlaying)
+}
+
+func (c *CachedService) GetTopArtists(ctx context.Context, limit int, period string) (*TopArtistsInfo, error) {
+	key := fmt.Sprintf("topartists:%d:%s", clampLimit(limit), validatePeriod(period))
+	return cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*TopArtistsInfo, error) {
+		return c.service.GetTopArtists(ctx, limit, period)
+	})
+}
+
+func (c *CachedService) GetTopTracks(ctx context.Context, limit int, period string) (*TopTracksInfo, error) {
+	key := fmt.Sprintf("toptracks:%d:%s", clampLimit(limit), validatePeriod(period))
+	info, err := cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*TopTracksInfo, error) {
+		return c.service.GetTopTracks(ctx, limit, period)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.markLoved(ctx, info), nil
+}
+
+// markLoved returns a copy of info with loved tracks flagged, leaving the cached value as is
+func (c *CachedService) markLoved(ctx context.Context, info *TopTracksInfo) *TopTracksInfo {
+	loved := c.lovedSet(ctx)
+	marked := &TopTracksInfo{Tracks: make([]TopTrack, len(info.Tracks))}
+	for i, track := range info.Tracks {
+		marked.Tracks[i] = track
+		marked.Tracks[i].Loved = loved[lovedKey(track.Artist, track.Name)]
+	}
+	return marked
+}
+
+func (c *CachedService) GetTopAlbums(ctx context.Context, limit int, period string) (*TopAlbumsInfo, error) {
+	key := fmt.Sprintf("topalbums:%d:%s", clampLimit(limit), validatePeriod(period))
+	return cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*TopAlbumsInfo, error) {
+		return c.service.GetTopAlbums(ctx, limit, period)
+	})
+}
+
+// GetTopArtistsRange caches merged weekly charts like the preset periods. Keys use the
+// requested bounds, which a client normally reuses.
+func (c *CachedService) GetTopArtistsRange(ctx context.Context, limit int, from, to time.Time) (*TopArtistsInfo, error) {
+	charts, ok := c.service.(rangeChartsProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	key := fmt.Sprintf("topartists:%d:%d-%d", clampLimit(limit), from.Unix(), to.Unix())
+	return cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*TopArtistsInfo, error) {
+		return charts.GetTopArtistsRange(ctx, limit, from, to)
+	})
+}
+
+func (c *CachedService) GetTopTracksRange(ctx context.Context, limit int, from, to time.Time) (*TopTracksInfo, error) {
+	charts, ok := c.service.(rangeChartsProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	key := fmt.Sprintf("toptracks:%d:%d-%d", clampLimit(limit), from.Unix(), to.Unix())
+	info, err := cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*TopTracksInfo, error) {
+		return charts.GetTopTracksRange(ctx, limit, from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.markLoved(ctx, info), nil
+}
+
+func (c *CachedService) GetTopAlbumsRange(ctx context.Context, limit int, from, to time.Time) (*TopAlbumsInfo, error) {
+	charts, ok := c.service.(rangeChartsProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	key := fmt.Sprintf("topalbums:%d:%d-%d", clampLimit(limit), from.Unix(), to.Unix())
+	return cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*TopAlbumsInfo, error) {
+		return charts.GetTopAlbumsRange(ctx, limit, from, to)
+	})
+}
+
+func (c *CachedService) GetArtistTrends(ctx context.Context, limit int, current, previous string) (*Trends, error) {
+	charts, ok := c.service.(rangeChartsProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	key := fmt.Sprintf("trends:%d:%s:%s", clampLimit(limit), periods[current], periods[previous])
+	return cached(ctx, c, key, c.ttls.TopCharts, func(ctx context.Context) (*Trends, error) {
+		return charts.GetArtistTrends(ctx, limit, current, previous)
+	})
+}
+
+// GetArtistDetail caches artist pages for a day under the name Last.fm corrected them to. Other
+// spellings that resolved to an artist only keep a pointer to its entry.
+func (c *CachedService) GetArtistDetail(ctx context.Context, name string) (*ArtistDetail, error) {
+	artists, ok := c.service.(artistDetailProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	requested := "artist:" + strings.ToLower(strings.TrimSpace(name))
+	key := requested
+	c.mu.Lock()
+	if alias, ok := c.entries["artistalias:"+requested]; ok && time.Now().Before(alias.expiresAt) {
+		key = alias.value.(string)
+	}
+	c.mu.Unlock()
+
+	detail, err := cachedAs(ctx, c, key, c.ttls.Artist, func(ctx context.Context) (*ArtistDetail, string, error) {
+		detail, err := artists.GetArtistDetail(ctx, name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		canonical := "artist:" + strings.ToLower(detail.Name)
+		if canonical != requested {
+			now := time.Now()
+			c.mu.Lock()
+			c.store("artistalias:"+requested, cacheEntry{value: canonical, fetchedAt: now, expiresAt: now.Add(c.ttls.Artist)})
+			c.mu.Unlock()
+		}
+		return detail, canonical, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The entry is shared by every spelling, so whether this one was corrected is per request
+	corrected := *detail
+	corrected.Corrected = !strings.EqualFold(strings.TrimSpace(name), detail.Name)
+	return &corrected, nil
+}
+
+func (c *CachedService) GetRecentTracks(ctx context.Context, limit int) (*RecentTracksInfo, error) {
+	key := fmt.Sprintf("recenttracks:%d", clampLimit(limit))
+	info, err := cached(ctx, c, key, c.ttls.RecentTracks, func(ctx context.Context) (*RecentTracksInfo, error) {
+		return c.service.GetRecentTracks(ctx, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loved := c.lovedSet(ctx)
+	marked := &RecentTracksInfo{Tracks: make([]RecentTrack, len(info.Tracks))}
+	for i, track := range info.Tracks {
+		marked.Tracks[i] = track
+		marked.Tracks[i].Loved = loved[lovedKey(track.Artist, track.Name)]
+	}
+	return marked, nil
+}
+
+func (c *CachedService) GetLovedTracks(ctx context.Context, page, limit int) (*LovedTracksInfo, error) {
+	loved, ok := c.service.(lovedTracksProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	key := fmt.Sprintf("lovedtracks:%d:%d", page, limit)
+	return cached(ctx, c, key, c.ttls.Loved, func(ctx context.Context) (*LovedTracksInfo, error) {
+		return loved.GetLovedTracks(ctx, page, limit)
+	})
+}
+
+const (
+	// Upper bound on pages walked to build the loved set, 10,000 tracks
+	maxLovedPages = 50
+
+	// How long to wait after a failed loved set build before trying again
+	lovedRetryAfter = time.Minute
+
+	// Upper bound on one loved set build
+	lovedBuildTimeout = 2 * time.Minute
+)
+
+// lovedSet returns every loved track keyed by lovedKey. Walking every page can take a while,
+// so an expired or missing set is rebuilt in the background while the previous one (or none)
+// is used. Tracks simply show as not loved until then, rather than holding up the request.
+func (c *CachedService) lovedSet(ctx context.Context) map[string]bool {
+	loved, ok := c.service.(lovedTracksProvider)
+	if !ok {
+		return nil
+	}
+
+	c.lovedMu.Lock()
+	defer c.lovedMu.Unlock()
+
+	age := time.Since(c.lovedAt)
+	if age >= c.ttls.Loved && !c.lovedBuilding && time.Since(c.lovedFailedAt) >= lovedRetryAfter {
+		c.lovedBuilding = true
+		go c.buildLovedSet(context.WithoutCancel(ctx), loved)
+	}
+
+	if age >= c.ttls.Loved+c.ttls.MaxStale {
+		return nil
+	}
+	return c.loved
+}
+
+// buildLovedSet walks the loved tracks and swaps in the new set, or records the failure
+func (c *CachedService) buildLovedSet(ctx context.Context, loved lovedTracksProvider) {
+	ctx, cancel := context.WithTimeout(ctx, lovedBuildTimeout)
+	defer cancel()
+
+	set := make(map[string]bool)
+	var err error
+	for page := 1; page <= maxLovedPages; page++ {
+		var info *LovedTracksInfo
+		if info, err = loved.GetLovedTracks(ctx, page, 200); err != nil {
+			break
+		}
+		for _, track := range info.Tracks {
+			set[lovedKey(track.Artist, track.Name)] = true
+		}
+		if page >= info.TotalPages {
+			break
+		}
+	}
+
+	c.lovedMu.Lock()
+	defer c.lovedMu.Unlock()
+	c.lovedBuilding = false
+	if err != nil {
+		log.Printf("Failed to fetch loved tracks: %v\n", err)
+		c.lovedFailedAt = time.Now()
+		return
+	}
+	c.loved = set
+	c.lovedAt = time.Now()
+}
+
+func (c *CachedService) GetListeningStats(ctx context.Context) (*ListeningStats, error) {
+	return cached(ctx, c, "stats", c.ttls.Stats, c.service.GetListeningStats)
+}
+
+// cached answers from the cache when the entry for key is fresh, otherwise refreshes it.
+// Cached values are shared between callers and must not be modified.
+func cached[T any](ctx context.Context, c *CachedService, key string, ttl time.Duration, fetch func(context.Context) (T, error)) (T, error) {
+	return cachedAs(ctx, c, key, ttl, func(ctx context.Context) (T, string, error) {
+		value, err := fetch(ctx)
+		return value, key, err
+	})
+}
+
+// cachedAs is cached for values whose key is only known once fetched; fetch returns the key
+// the value is stored under
+func cachedAs[T any](ctx context.Context, c *CachedService, key string, ttl time.Duration, fetch func(context.Context) (T, string, error)) (T, error) {
+	status, _ := ctx.Value(cacheStatusKey{}).(*CacheStatus)
+	if status == nil {
+		status = &CacheStatus{}
+	}
+	status.MaxAge = ttl
+	status.StaleIfError = c.ttls.MaxStale
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && time.Since(entry.fetchedAt) < ttl {
+		status.Hit = true
+		status.Age = time.Since(entry.fetchedAt)
+		return entry.value.(T), nil
+	}
+
+	// The shared fetch must outlive whichever caller happened to start it
+	result, err, _ := c.group.Do(key, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+
+		value, storeKey, err := fetch(fetchCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		c.mu.Lock()
+		c.store(storeKey, cacheEntry{value: value, fetchedAt: now, expiresAt: now.Add(ttl + c.ttls.MaxStale)})
+		c.mu.Unlock()
+		return value, nil
+	})
+
+	if err != nil {
+		if ok && time.Since(entry.fetchedAt) < ttl+c.ttls.MaxStale {
+			status.Hit = true
+			status.Stale = true
+			status.Age = time.Since(entry.fetchedAt)
+			return entry.value.(T), nil
+		}
+
+		var zero T
+		return zero, err
+	}
+
+	return result.(T), nil
+}
+
+// store adds an entry, first dropping expired ones and then the oldest when the cache is full.
+// c.mu must be held.
+func (c *CachedService) store(key string, entry cacheEntry) {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCacheEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+
+		for len(c.entries) >= maxCacheEntries {
+			var oldest string
+			for k, e := range c.entries {
+				if oldest == "" || e.fetchedAt.Before(c.entries[oldest].fetchedAt) {
+					oldest = k
+				}
+			}
+			delete(c.entries, oldest)
+		}
+	}
+
+	c.entries[key] = entry
+}
@@ -0,0 +1,133 @@
+package lastfm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCacheIsBounded(t *testing.T) {
+	c := NewCachedService(nil, DefaultCacheTTLs)
+	c.entries["expired"] = cacheEntry{value: 0, fetchedAt: time.Now().Add(-48 * time.Hour), expiresAt: time.Now().Add(-time.Hour)}
+
+	for i := 0; i < maxCacheEntries+10; i++ {
+		key := fmt.Sprintf("topartists:10:%d-%d", i, i+1)
+		if _, err := cached(context.Background(), c, key, time.Hour, func(context.Context) (int, error) { return i, nil }); err != nil {
+			t.Fatalf("cached: %v", err)
+		}
+	}
+
+	if len(c.entries) != maxCacheEntries {
+		t.Errorf("cache holds %d entries, want %d", len(c.entries), maxCacheEntries)
+	}
+	if _, ok := c.entries["expired"]; ok {
+		t.Error("expired entry survived")
+	}
+	if _, ok := c.entries[fmt.Sprintf("topartists:10:%d-%d", maxCacheEntries+9, maxCacheEntries+10)]; !ok {
+		t.Error("newest entry was evicted")
+	}
+	if _, ok := c.entries["topartists:10:0-1"]; ok {
+		t.Error("oldest entry survived")
+	}
+}
+
+func TestArtistDetailCachedUnderCorrectedName(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"artist.getinfo":    {file: "artistinfo_unplayed.json"},
+		"artist.getsimilar": {file: "similarartists_empty.json"},
+	})
+	c := NewCachedService(service, DefaultCacheTTLs)
+
+	requests := []struct {
+		name          string
+		wantCorrected bool
+	}{
+		{"Burrial", true},
+		{" burrial ", true},
+		{"Burial", false},
+		{"Burrial", true},
+	}
+	for _, r := range requests {
+		detail, err := c.GetArtistDetail(context.Background(), r.name)
+		if err != nil {
+			t.Fatalf("GetArtistDetail(%q): %v", r.name, err)
+		}
+		if detail.Name != "Burial" || detail.Corrected != r.wantCorrected {
+			t.Errorf("GetArtistDetail(%q) = %q, corrected %v; want Burial, corrected %v", r.name, detail.Name, detail.Corrected, r.wantCorrected)
+		}
+	}
+
+	if _, ok := c.entries["artist:burial"]; !ok {
+		t.Error("detail is not cached under the corrected name")
+	}
+	if _, ok := c.entries["artist:burrial"]; ok {
+		t.Error("detail is cached under the misspelling")
+	}
+	if alias := c.entries["artistalias:artist:burrial"]; alias.value != "artist:burial" {
+		t.Errorf("alias = %v, want artist:burial", alias.value)
+	}
+
+	calls := 0
+	for _, query := range rec.all() {
+		if query.Get("method") == "artist.getinfo" {
+			calls++
+		}
+	}
+	if calls != 1 {
+		t.Errorf("artist.getinfo called %d times, want 1", calls)
+	}
+}
+
+// waitForLovedSet waits for the background loved set build to finish
+func waitForLovedSet(t *testing.T, c *CachedService) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.lovedMu.Lock()
+		building := c.lovedBuilding
+		c.lovedMu.Unlock()
+		if !building {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("loved set build did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLovedSetBuildsInBackground(t *testing.T) {
+	service, _ := newFixtureServer(t, map[string]fixture{
+		"user.getlovedtracks": {file: "lovedtracks_single.json"},
+	})
+	c := NewCachedService(service, DefaultCacheTTLs)
+
+	// A cold cache answers straight away without loved flags
+	if set := c.lovedSet(context.Background()); set != nil {
+		t.Errorf("cold loved set = %v, want nil", set)
+	}
+	waitForLovedSet(t, c)
+
+	if set := c.lovedSet(context.Background()); !set[lovedKey("burial", "ARCHANGEL")] {
+		t.Errorf("loved set = %v, want Archangel by Burial", set)
+	}
+}
+
+func TestLovedSetFailureIsCached(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getlovedtracks": {file: "error_operation_failed.json", status: 500},
+	})
+	c := NewCachedService(service, DefaultCacheTTLs)
+
+	for i := 0; i < 3; i++ {
+		if set := c.lovedSet(context.Background()); set != nil {
+			t.Errorf("loved set = %v, want nil", set)
+		}
+		waitForLovedSet(t, c)
+	}
+
+	if n := len(rec.all()); n != 1 {
+		t.Errorf("loved tracks fetched %d times, want 1 until the retry delay passes", n)
+	}
+}
@@ -0,0 +1,365 @@
+package lastfm
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Longest range the top-* endpoints merge weekly charts over, one request per week
+	maxRangeWeeks = 53
+
+	// Weekly charts fetched at once when merging a range
+	chartConcurrency = 4
+
+	// Most user.getrecenttracks pages read for one partial week, 10,000 scrobbles
+	maxGapPages = 50
+)
+
+var ErrInvalidRange = errors.New("range must end after it starts and span at most 53 weeks")
+
+// ChartRange is one of the weekly periods Last.fm publishes charts for
+type ChartRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// ChartEntry is an artist, track or album with its plays over a chart range. Artist is empty
+// for artist charts.
+type ChartEntry struct {
+	Name      string `json:"name"`
+	Artist    string `json:"artist,omitempty"`
+	PlayCount int    `json:"playcount"`
+	URL       string `json:"url,omitempty"`
+}
+
+type weeklyChartListResponse struct {
+	WeeklyChartList struct {
+		Chart oneOrMany[struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}] `json:"chart"`
+	} `json:"weeklychartlist"`
+}
+
+type weeklyArtistChartResponse struct {
+	WeeklyArtistChart struct {
+		Artist oneOrMany[struct {
+			Name      string `json:"name"`
+			PlayCount string `json:"playcount"`
+			URL       string `json:"url"`
+		}] `json:"artist"`
+	} `json:"weeklyartistchart"`
+}
+
+type weeklyTrackChartResponse struct {
+	WeeklyTrackChart struct {
+		Track oneOrMany[struct {
+			Name   string `json:"name"`
+			Artist struct {
+				Text string `json:"#text"`
+			} `json:"artist"`
+			PlayCount string `json:"playcount"`
+			URL       string `json:"url"`
+		}] `json:"track"`
+	} `json:"weeklytrackchart"`
+}
+
+type weeklyAlbumChartResponse struct {
+	WeeklyAlbumChart struct {
+		Album oneOrMany[struct {
+			Name   string `json:"name"`
+			Artist struct {
+				Text string `json:"#text"`
+			} `json:"artist"`
+			PlayCount string `json:"playcount"`
+			URL       string `json:"url"`
+		}] `json:"album"`
+	} `json:"weeklyalbumchart"`
+}
+
+// GetWeeklyChartList returns every weekly range Last.fm has charts for, oldest first
+func (s *Service) GetWeeklyChartList(ctx context.Context) ([]ChartRange, error) {
+	var lastfmResp weeklyChartListResponse
+	if err := s.client.Call(ctx, "user.getweeklychartlist", s.userParams(), &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	ranges := make([]ChartRange, 0, len(lastfmResp.WeeklyChartList.Chart))
+	for _, chart := range lastfmResp.WeeklyChartList.Chart {
+		from, errFrom := strconv.ParseInt(chart.From, 10, 64)
+		to, errTo := strconv.ParseInt(chart.To, 10, 64)
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		ranges = append(ranges, ChartRange{From: time.Unix(from, 0).UTC(), To: time.Unix(to, 0).UTC()})
+	}
+	return ranges, nil
+}
+
+// chartParams returns the user, from and to parameters of the user.getweekly*chart methods
+func (s *Service) chartParams(from, to time.Time) url.Values {
+	params := s.userParams()
+	params.Set("from", strconv.FormatInt(from.Unix(), 10))
+	params.Set("to", strconv.FormatInt(to.Unix(), 10))
+	return params
+}
+
+// GetWeeklyArtistChart returns the plays per artist between from and to
+func (s *Service) GetWeeklyArtistChart(ctx context.Context, from, to time.Time) ([]ChartEntry, error) {
+	var lastfmResp weeklyArtistChartResponse
+	if err := s.client.Call(ctx, "user.getweeklyartistchart", s.chartParams(from, to), &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ChartEntry, 0, len(lastfmResp.WeeklyArtistChart.Artist))
+	for _, artist := range lastfmResp.WeeklyArtistChart.Artist {
+		plays, _ := strconv.Atoi(artist.PlayCount)
+		entries = append(entries, ChartEntry{Name: artist.Name, PlayCount: plays, URL: artist.URL})
+	}
+	return entries, nil
+}
+
+// GetWeeklyTrackChart returns the plays per track between from and to
+func (s *Service) GetWeeklyTrackChart(ctx context.Context, from, to time.Time) ([]ChartEntry, error) {
+	var lastfmResp weeklyTrackChartResponse
+	if err := s.client.Call(ctx, "user.getweeklytrackchart", s.chartParams(from, to), &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ChartEntry, 0, len(lastfmResp.WeeklyTrackChart.Track))
+	for _, track := range lastfmResp.WeeklyTrackChart.Track {
+		plays, _ := strconv.Atoi(track.PlayCount)
+		entries = append(entries, ChartEntry{Name: track.Name, Artist: track.Artist.Text, PlayCount: plays, URL: track.URL})
+	}
+	return entries, nil
+}
+
+// GetWeeklyAlbumChart returns the plays per album between from and to
+func (s *Service) GetWeeklyAlbumChart(ctx context.Context, from, to time.Time) ([]ChartEntry, error) {
+	var lastfmResp weeklyAlbumChartResponse
+	if err := s.client.Call(ctx, "user.getweeklyalbumchart", s.chartParams(from, to), &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ChartEntry, 0, len(lastfmResp.WeeklyAlbumChart.Album))
+	for _, album := range lastfmResp.WeeklyAlbumChart.Album {
+		plays, _ := strconv.Atoi(album.PlayCount)
+		entries = append(entries, ChartEntry{Name: album.Name, Artist: album.Artist.Text, PlayCount: plays, URL: album.URL})
+	}
+	return entries, nil
+}
+
+// ChartWeeks splits from to to into the published chart weeks lying wholly inside it and the
+// gaps around them, the partial weeks at either end and any week Last.fm hasn't published yet.
+func (s *Service) ChartWeeks(ctx context.Context, from, to time.Time) (weeks, gaps []ChartRange, err error) {
+	if err := ValidateRange(from, to); err != nil {
+		return nil, nil, err
+	}
+
+	ranges, err := s.GetWeeklyChartList(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursor := from
+	for _, r := range ranges {
+		if r.From.Before(from) || r.To.After(to) {
+			continue
+		}
+		if r.From.After(cursor) {
+			gaps = append(gaps, ChartRange{From: cursor, To: r.From})
+		}
+		weeks = append(weeks, r)
+		cursor = r.To
+	}
+	if to.After(cursor) {
+		gaps = append(gaps, ChartRange{From: cursor, To: to})
+	}
+	return weeks, gaps, nil
+}
+
+// ValidateRange returns ErrInvalidRange unless to is after from and at most 53 weeks later
+func ValidateRange(from, to time.Time) error {
+	if !to.After(from) || to.Sub(from) > maxRangeWeeks*7*24*time.Hour {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// mergedChart sums the artist, track or album charts between from and to, most played first.
+// Whole weeks come from weekly charts and the gaps around them from the scrobbles themselves,
+// so a window ending now includes the week in progress.
+func (s *Service) mergedChart(ctx context.Context, kind string, from, to time.Time) ([]ChartEntry, error) {
+	weeks, gaps, err := s.ChartWeeks(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fetch := s.GetWeeklyArtistChart
+	switch kind {
+	case "track":
+		fetch = s.GetWeeklyTrackChart
+	case "album":
+		fetch = s.GetWeeklyAlbumChart
+	}
+
+	spans := append(weeks, gaps...)
+	charts := make([][]ChartEntry, len(spans))
+	errs := make([]error, len(spans))
+	sem := make(chan struct{}, chartConcurrency)
+
+	var wg sync.WaitGroup
+	for i, span := range spans {
+		wg.Add(1)
+		go func(i int, span ChartRange, gap bool) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if gap {
+				charts[i], errs[i] = s.scrobbleChart(ctx, kind, span.From, span.To)
+			} else {
+				charts[i], errs[i] = fetch(ctx, span.From, span.To)
+			}
+		}(i, span, i >= len(weeks))
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return MergeCharts(charts...), nil
+}
+
+// scrobbleChart tallies the plays between from and to (end exclusive, like chart weeks) by
+// paging through the scrobbles. Gaps are under a week or two, so only a few pages are needed;
+// maxGapPages stops a runaway on an unusually heavy week.
+func (s *Service) scrobbleChart(ctx context.Context, kind string, from, to time.Time) ([]ChartEntry, error) {
+	var entries []ChartEntry
+	for page := 1; page <= maxGapPages; page++ {
+		result, err := s.GetRecentTracksPage(ctx, page, 200, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, scrobble := range result.Scrobbles {
+			if scrobble.PlayedAt.Before(from) || !scrobble.PlayedAt.Before(to) {
+				continue
+			}
+			switch kind {
+			case "artist":
+				entries = append(entries, ChartEntry{Name: scrobble.Artist, PlayCount: 1})
+			case "track":
+				entries = append(entries, ChartEntry{Name: scrobble.Track, Artist: scrobble.Artist, PlayCount: 1, URL: scrobble.URL})
+			case "album":
+				if scrobble.Album != "" {
+					entries = append(entries, ChartEntry{Name: scrobble.Album, Artist: scrobble.Artist, PlayCount: 1})
+				}
+			}
+		}
+
+		if page >= result.TotalPages || len(result.Scrobbles) == 0 {
+			break
+		}
+	}
+	return MergeCharts(entries), nil
+}
+
+// MergeCharts adds up the plays of entries with the same name and artist across charts,
+// ignoring case. The result is sorted by plays, ties broken by name.
+func MergeCharts(charts ...[]ChartEntry) []ChartEntry {
+	merged := make(map[string]*ChartEntry)
+	for _, chart := range charts {
+		for _, e := range chart {
+			key := strings.ToLower(e.Artist) + "\x1f" + strings.ToLower(e.Name)
+			if existing, ok := merged[key]; ok {
+				existing.PlayCount += e.PlayCount
+				continue
+			}
+			entry := e
+			merged[key] = &entry
+		}
+	}
+
+	entries := make([]ChartEntry, 0, len(merged))
+	for _, e := range merged {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].PlayCount != entries[j].PlayCount {
+			return entries[i].PlayCount > entries[j].PlayCount
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// GetTopArtistsRange returns the top artists between from and to, merged from weekly charts
+func (s *Service) GetTopArtistsRange(ctx context.Context, limit int, from, to time.Time) (*TopArtistsInfo, error) {
+	entries, err := s.mergedChart(ctx, "artist", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TopArtistsInfo{Artists: make([]TopArtist, 0)}
+	for _, e := range topEntries(entries, limit) {
+		info.Artists = append(info.Artists, TopArtist{
+			Name:      e.Name,
+			PlayCount: strconv.Itoa(e.PlayCount),
+		})
+	}
+	return info, nil
+}
+
+// GetTopTracksRange returns the top tracks between from and to. Weekly charts carry no
+// images, so tracks have no album art.
+func (s *Service) GetTopTracksRange(ctx context.Context, limit int, from, to time.Time) (*TopTracksInfo, error) {
+	entries, err := s.mergedChart(ctx, "track", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TopTracksInfo{Tracks: make([]TopTrack, 0)}
+	for _, e := range topEntries(entries, limit) {
+		info.Tracks = append(info.Tracks, TopTrack{
+			Name:      e.Name,
+			Artist:    e.Artist,
+			PlayCount: strconv.Itoa(e.PlayCount),
+			URL:       e.URL,
+		})
+	}
+	return info, nil
+}
+
+// GetTopAlbumsRange returns the top albums between from and to, without album art
+func (s *Service) GetTopAlbumsRange(ctx context.Context, limit int, from, to time.Time) (*TopAlbumsInfo, error) {
+	entries, err := s.mergedChart(ctx, "album", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TopAlbumsInfo{Albums: make([]TopAlbum, 0)}
+	for _, e := range topEntries(entries, limit) {
+		info.Albums = append(info.Albums, TopAlbum{
+			Name:      e.Name,
+			Artist:    e.Artist,
+			PlayCount: strconv.Itoa(e.PlayCount),
+			URL:       e.URL,
+		})
+	}
+	return info, nil
+}
+
+func topEntries(entries []ChartEntry, limit int) []ChartEntry {
+	if limit = clampLimit(limit); len(entries) > limit {
+		return entries[:limit]
+	}
+	return entries
+}
@@ -0,0 +1,190 @@
+package lastfm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetWeeklyChartList(t *testing.T) {
+	service, _ := newFixtureServer(t, map[string]fixture{
+		"user.getweeklychartlist": {file: "weeklychartlist.json"},
+	})
+
+	ranges, err := service.GetWeeklyChartList(context.Background())
+	if err != nil {
+		t.Fatalf("GetWeeklyChartList: %v", err)
+	}
+	// The range with an unparseable start is skipped
+	want := []ChartRange{
+		{From: time.Unix(1699790400, 0).UTC(), To: time.Unix(1700395200, 0).UTC()},
+		{From: time.Unix(1700395200, 0).UTC(), To: time.Unix(1701000000, 0).UTC()},
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("got %d ranges, want %d", len(ranges), len(want))
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("range %d = %+v, want %+v", i, ranges[i], want[i])
+		}
+	}
+}
+
+func TestWeeklyCharts(t *testing.T) {
+	from := time.Unix(1699790400, 0)
+	to := time.Unix(1700395200, 0)
+
+	tests := []struct {
+		name   string
+		method string
+		file   string
+		fetch  func(s *Service) ([]ChartEntry, error)
+		want   []ChartEntry
+	}{
+		{
+			name:   "artists",
+			method: "user.getweeklyartistchart",
+			file:   "weeklyartistchart.json",
+			fetch: func(s *Service) ([]ChartEntry, error) {
+				return s.GetWeeklyArtistChart(context.Background(), from, to)
+			},
+			want: []ChartEntry{
+				{Name: "Burial", PlayCount: 25, URL: "https://www.last.fm/music/Burial"},
+				{Name: "Four Tet", PlayCount: 12, URL: "https://www.last.fm/music/Four+Tet"},
+			},
+		},
+		{
+			name:   "single artist sent as an object",
+			method: "user.getweeklyartistchart",
+			file:   "weeklyartistchart_single.json",
+			fetch: func(s *Service) ([]ChartEntry, error) {
+				return s.GetWeeklyArtistChart(context.Background(), from, to)
+			},
+			want: []ChartEntry{{Name: "Burial", PlayCount: 25, URL: "https://www.last.fm/music/Burial"}},
+		},
+		{
+			name:   "empty week",
+			method: "user.getweeklyartistchart",
+			file:   "weeklychart_empty.json",
+			fetch: func(s *Service) ([]ChartEntry, error) {
+				return s.GetWeeklyArtistChart(context.Background(), from, to)
+			},
+			want: []ChartEntry{},
+		},
+		{
+			name:   "tracks",
+			method: "user.getweeklytrackchart",
+			file:   "weeklytrackchart.json",
+			fetch: func(s *Service) ([]ChartEntry, error) {
+				return s.GetWeeklyTrackChart(context.Background(), from, to)
+			},
+			want: []ChartEntry{{Name: "Archangel", Artist: "Burial", PlayCount: 9, URL: "https://www.last.fm/music/Burial/_/Archangel"}},
+		},
+		{
+			name:   "albums",
+			method: "user.getweeklyalbumchart",
+			file:   "weeklyalbumchart.json",
+			fetch: func(s *Service) ([]ChartEntry, error) {
+				return s.GetWeeklyAlbumChart(context.Background(), from, to)
+			},
+			want: []ChartEntry{{Name: "Untrue", Artist: "Burial", PlayCount: 14, URL: "https://www.last.fm/music/Burial/Untrue"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, rec := newFixtureServer(t, map[string]fixture{tt.method: {file: tt.file}})
+
+			entries, err := tt.fetch(service)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.method, err)
+			}
+			if entries == nil || len(entries) != len(tt.want) {
+				t.Fatalf("got %#v, want %#v", entries, tt.want)
+			}
+			for i := range tt.want {
+				if entries[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, entries[i], tt.want[i])
+				}
+			}
+
+			query := rec.last(t, tt.method)
+			if query.Get("from") != "1699790400" || query.Get("to") != "1700395200" || query.Get("user") != "tringl" {
+				t.Errorf("unexpected query: %v", query)
+			}
+		})
+	}
+}
+
+func TestGetTopArtistsRange(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getweeklychartlist":   {file: "weeklychartlist.json"},
+		"user.getweeklyartistchart": {file: "weeklyartistchart.json"},
+	})
+
+	info, err := service.GetTopArtistsRange(context.Background(), 1, time.Unix(1699790400, 0), time.Unix(1701000000, 0))
+	if err != nil {
+		t.Fatalf("GetTopArtistsRange: %v", err)
+	}
+	// Both weeks serve the same fixture, so every play count doubles
+	if len(info.Artists) != 1 || info.Artists[0].Name != "Burial" || info.Artists[0].PlayCount != "50" {
+		t.Errorf("unexpected artists: %+v", info.Artists)
+	}
+
+	weeks := 0
+	for _, query := range rec.all() {
+		if query.Get("method") == "user.getweeklyartistchart" {
+			weeks++
+		}
+	}
+	if weeks != 2 {
+		t.Errorf("fetched %d weekly charts, want 2", weeks)
+	}
+
+	if _, err := service.GetTopArtistsRange(context.Background(), 10, time.Unix(1701000000, 0), time.Unix(1699790400, 0)); err != ErrInvalidRange {
+		t.Errorf("reversed range error = %v, want ErrInvalidRange", err)
+	}
+}
+
+func TestGetTopArtistsRangeEndingMidWeek(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getweeklychartlist":   {file: "weeklychartlist.json"},
+		"user.getweeklyartistchart": {file: "weeklyartistchart.json"},
+		"user.getrecenttracks":      {file: "recenttracks_single.json"},
+	})
+
+	// Starts inside the first chart week and ends after the second, in a week not published yet
+	from := time.Unix(1700000000, 0)
+	to := time.Unix(1701300000, 0)
+	info, err := service.GetTopArtistsRange(context.Background(), 10, from, to)
+	if err != nil {
+		t.Fatalf("GetTopArtistsRange: %v", err)
+	}
+
+	// The second week's chart plus the one fixture scrobble inside the leading gap
+	want := []TopArtist{{Name: "Burial", PlayCount: "25"}, {Name: "Four Tet", PlayCount: "12"}, {Name: "Aphex Twin", PlayCount: "1"}}
+	if len(info.Artists) != len(want) {
+		t.Fatalf("got %+v, want %+v", info.Artists, want)
+	}
+	for i := range want {
+		if info.Artists[i].Name != want[i].Name || info.Artists[i].PlayCount != want[i].PlayCount {
+			t.Errorf("artist %d = %+v, want %+v", i, info.Artists[i], want[i])
+		}
+	}
+
+	var charts, gaps []string
+	for _, query := range rec.all() {
+		switch query.Get("method") {
+		case "user.getweeklyartistchart":
+			charts = append(charts, query.Get("from")+"-"+query.Get("to"))
+		case "user.getrecenttracks":
+			gaps = append(gaps, query.Get("from")+"-"+query.Get("to"))
+		}
+	}
+	if len(charts) != 1 || charts[0] != "1700395200-1701000000" {
+		t.Errorf("weekly charts fetched for %v, want only the whole week", charts)
+	}
+	if len(gaps) != 2 {
+		t.Errorf("scrobbles paged for %v, want the leading and trailing gaps", gaps)
+	}
+}
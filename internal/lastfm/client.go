@@ -0,0 +1,195 @@
+package lastfm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://ws.audioscrobbler.com/2.0/"
+
+	defaultRetries = 3
+	defaultBackoff = 250 * time.Millisecond
+
+	// Far above the largest real response, a 1000 track page is around 1MB
+	maxResponseSize = 16 << 20
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("lastfm: invalid api key")
+	ErrNotFound      = errors.New("lastfm: user or item not found")
+	ErrRateLimited   = errors.New("lastfm: rate limit exceeded")
+	ErrUnavailable   = errors.New("lastfm: service temporarily unavailable")
+)
+
+// Last.fm error codes, see https://www.last.fm/api/errorcodes
+const (
+	codeInvalidParameters = 6
+	codeOperationFailed   = 8
+	codeInvalidAPIKey     = 10
+	codeServiceOffline    = 11
+	codeTemporaryError    = 16
+	codeSuspendedAPIKey   = 26
+	codeRateLimitExceeded = 29
+)
+
+// APIError is an error body returned by Last.fm, e.g. {"error":6,"message":"User not found"},
+// or a non-200 response without one. It matches ErrInvalidAPIKey, ErrNotFound, ErrRateLimited
+// and ErrUnavailable with errors.Is; any 5xx counts as unavailable.
+type APIError struct {
+	StatusCode int
+	Code       int    `json:"error"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("last.fm api error %d (http %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrInvalidAPIKey:
+		return e.Code == codeInvalidAPIKey || e.Code == codeSuspendedAPIKey
+	case ErrNotFound:
+		return e.Code == codeInvalidParameters
+	case ErrRateLimited:
+		return e.Code == codeRateLimitExceeded
+	case ErrUnavailable:
+		return e.Code == codeOperationFailed || e.Code == codeServiceOffline || e.Code == codeTemporaryError ||
+			e.StatusCode >= 500
+	}
+	return false
+}
+
+// Client performs Last.fm API calls. The zero value is not usable, create one with NewClient.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+
+	// Retries after the first attempt for 5xx responses, timeouts and temporary errors
+	MaxRetries int
+	// Delay before the first retry, doubled for each one after
+	Backoff time.Duration
+}
+
+// NewClient creates a client for the API at baseURL (DefaultBaseURL when empty) using
+// httpClient (a client with a 10 second timeout when nil)
+func NewClient(baseURL, apiKey string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: httpClient,
+		MaxRetries: defaultRetries,
+		Backoff:    defaultBackoff,
+	}
+}
+
+// Call invokes an API method with params and decodes the JSON response into out
+func (c *Client) Call(ctx context.Context, method string, params url.Values, out any) error {
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	query.Set("method", method)
+	query.Set("api_key", c.apiKey)
+	query.Set("format", "json")
+
+	apiURL := c.baseURL + "?" + query.Encode()
+
+	var lastErr error
+	backoff := c.Backoff
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		body, status, err := c.get(ctx, apiURL)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to fetch from Last.fm: %w", err)
+			if ctx.Err() == nil && isRetryable(err) {
+				continue
+			}
+			return lastErr
+		}
+
+		// Last.fm reports errors in the body, sometimes with a 200 status
+		var apiErr APIError
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Code != 0 {
+			apiErr.StatusCode = status
+			lastErr = &apiErr
+			if errors.Is(lastErr, ErrUnavailable) {
+				continue
+			}
+			return lastErr
+		}
+
+		if status != http.StatusOK {
+			lastErr = &APIError{StatusCode: status, Message: truncate(string(body), 200)}
+			if status >= 500 {
+				continue
+			}
+			return lastErr
+		}
+
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+func (c *Client) get(ctx context.Context, apiURL string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+func isRetryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package lastfm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  fixture
+		want     error
+		wantCode int
+	}{
+		{"user not found with 200", fixture{file: "error_user_not_found.json"}, ErrNotFound, codeInvalidParameters},
+		{"user not found with 404", fixture{file: "error_user_not_found.json", status: http.StatusNotFound}, ErrNotFound, codeInvalidParameters},
+		{"invalid api key", fixture{file: "error_invalid_api_key.json", status: http.StatusForbidden}, ErrInvalidAPIKey, codeInvalidAPIKey},
+		{"rate limited", fixture{file: "error_rate_limited.json", status: http.StatusTooManyRequests}, ErrRateLimited, codeRateLimitExceeded},
+		{"operation failed", fixture{file: "error_operation_failed.json", status: http.StatusInternalServerError}, ErrUnavailable, codeOperationFailed},
+		{"html error page", fixture{file: "error_bad_gateway.html", status: http.StatusBadGateway}, ErrUnavailable, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newFixtureServer(t, map[string]fixture{
+				"user.getrecenttracks": tt.fixture,
+			})
+
+			_, err := service.GetRecentTracks(context.Background(), 10)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an *APIError", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want errors.Is %v", err, tt.want)
+			}
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("Code = %d, want %d", apiErr.Code, tt.wantCode)
+			}
+			wantStatus := tt.fixture.status
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if apiErr.StatusCode != wantStatus {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, wantStatus)
+			}
+		})
+	}
+}
+
+func TestRetriesTemporaryErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			serveFixture(t, w, fixture{file: "error_operation_failed.json", status: http.StatusInternalServerError})
+			return
+		}
+		serveFixture(t, w, fixture{file: "userinfo.json"})
+	}))
+	defer server.Close()
+
+	service := newTestService(server)
+	service.client.MaxRetries = 1
+	service.client.Backoff = time.Millisecond
+
+	stats, err := service.GetListeningStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetListeningStats: %v", err)
+	}
+	if stats.TotalScrobbles != "54321" || calls.Load() != 2 {
+		t.Errorf("got %+v after %d calls, want success on the second", stats, calls.Load())
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		serveFixture(t, w, fixture{file: "error_user_not_found.json", status: http.StatusNotFound})
+	}))
+	defer server.Close()
+
+	service := newTestService(server)
+	service.client.MaxRetries = 3
+	service.client.Backoff = time.Millisecond
+
+	if _, err := service.GetListeningStats(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("error = %v, want ErrNotFound", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("made %d calls, want 1", calls.Load())
+	}
+}
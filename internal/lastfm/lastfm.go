@@ -1,10 +1,8 @@
 package lastfm
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"strconv"
 	"time"
@@ -14,30 +12,66 @@ import (
 
 type Service struct {
 	config *config.Config
+	client *Client
+	// The user every user.* method asks about, LastFMUsername unless set by ForUser
+	username string
+
+	// Applied to every album art URL handed out, see SetAlbumArtRewriter
+	rewriteArt func(string) string
+}
+
+type image struct {
+	Text string `json:"#text"`
+	Size string `json:"size"`
+}
+
+// oneOrMany decodes a list that Last.fm sends as a bare object when it has a single element
+type oneOrMany[T any] []T
+
+func (o *oneOrMany[T]) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var single T
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*o = oneOrMany[T]{single}
+		return nil
+	}
+
+	var many []T
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*o = many
+	return nil
+}
+
+type recentTrack struct {
+	Name   string `json:"name"`
+	Artist struct {
+		Text string `json:"#text"`
+	} `json:"artist"`
+	Album struct {
+		Text string `json:"#text"`
+	} `json:"album"`
+	Image []image `json:"image"`
+	URL   string  `json:"url"`
+	Attr  struct {
+		NowPlaying string `json:"nowplaying"`
+	} `json:"@attr"`
+	Date struct {
+		UTS string `json:"uts"`
+	} `json:"date"`
 }
 
 type recentTracksResponse struct {
 	RecentTracks struct {
-		Track []struct {
-			Name   string `json:"name"`
-			Artist struct {
-				Text string `json:"#text"`
-			} `json:"artist"`
-			Album struct {
-				Text string `json:"#text"`
-			} `json:"album"`
-			Image []struct {
-				Text string `json:"#text"`
-				Size string `json:"size"`
-			} `json:"image"`
-			URL  string `json:"url"`
-			Attr struct {
-				NowPlaying string `json:"nowplaying"`
-			} `json:"@attr"`
-			Date struct {
-				UTS string `json:"uts"`
-			} `json:"date"`
-		} `json:"track"`
+		Track oneOrMany[recentTrack] `json:"track"`
+		Attr  struct {
+			Page       string `json:"page"`
+			TotalPages string `json:"totalPages"`
+			Total      string `json:"total"`
+		} `json:"@attr"`
 	} `json:"recenttracks"`
 }
 
@@ -54,17 +88,18 @@ type NowPlayingInfo struct {
 
 type topArtistsResponse struct {
 	TopArtists struct {
-		Artist []struct {
+		Artist oneOrMany[struct {
 			Name      string `json:"name"`
 			PlayCount string `json:"playcount"`
 			URL       string `json:"url"`
-		} `json:"artist"`
+		}] `json:"artist"`
 	} `json:"topartists"`
 }
 
 type TopArtist struct {
-	Name      string `json:"name"`
-	PlayCount string `json:"playcount"`
+	Name      string   `json:"name"`
+	PlayCount string   `json:"playcount"`
+	Tags      []string `json:"tags,omitempty"`
 }
 
 type TopArtistsInfo struct {
@@ -73,18 +108,15 @@ type TopArtistsInfo struct {
 
 type topTracksResponse struct {
 	TopTracks struct {
-		Track []struct {
+		Track oneOrMany[struct {
 			Name      string `json:"name"`
 			PlayCount string `json:"playcount"`
 			URL       string `json:"url"`
 			Artist    struct {
 				Name string `json:"name"`
 			} `json:"artist"`
-			Image []struct {
-				Text string `json:"#text"`
-				Size string `json:"size"`
-			} `json:"image"`
-		} `json:"track"`
+			Image []image `json:"image"`
+		}] `json:"track"`
 	} `json:"toptracks"`
 }
 
@@ -94,6 +126,7 @@ type TopTrack struct {
 	PlayCount string `json:"playcount"`
 	AlbumArt  string `json:"albumArt,omitempty"`
 	URL       string `json:"url"`
+	Loved     bool   `json:"loved"`
 }
 
 type TopTracksInfo struct {
@@ -102,18 +135,15 @@ type TopTracksInfo struct {
 
 type topAlbumsResponse struct {
 	TopAlbums struct {
-		Album []struct {
+		Album oneOrMany[struct {
 			Name      string `json:"name"`
 			PlayCount string `json:"playcount"`
 			URL       string `json:"url"`
 			Artist    struct {
 				Name string `json:"name"`
 			} `json:"artist"`
-			Image []struct {
-				Text string `json:"#text"`
-				Size string `json:"size"`
-			} `json:"image"`
-		} `json:"album"`
+			Image []image `json:"image"`
+		}] `json:"album"`
 	} `json:"topalbums"`
 }
 
@@ -137,6 +167,7 @@ type RecentTrack struct {
 	URL       string `json:"url"`
 	PlayedAt  string `json:"playedAt"`
 	IsPlaying bool   `json:"isPlaying"`
+	Loved     bool   `json:"loved"`
 }
 
 type RecentTracksInfo struct {
@@ -153,40 +184,93 @@ type userInfoResponse struct {
 	} `json:"user"`
 }
 
+// Scrobble is a single dated play from the user's history
+type Scrobble struct {
+	Track    string    `json:"track"`
+	Artist   string    `json:"artist"`
+	Album    string    `json:"album"`
+	AlbumArt string    `json:"albumArt,omitempty"`
+	URL      string    `json:"url"`
+	PlayedAt time.Time `json:"playedAt"`
+}
+
+type RecentTracksPage struct {
+	// Dated scrobbles only, the now-playing entry Last.fm puts on top is left out
+	Scrobbles  []Scrobble
+	Page       int
+	TotalPages int
+	Total      int
+}
+
 type ListeningStats struct {
-	TotalScrobbles string `json:"totalScrobbles"`
-	AccountAge     string `json:"accountAge"`
-	Username       string `json:"username"`
+	TotalScrobbles string      `json:"totalScrobbles"`
+	AccountAge     *AccountAge `json:"accountAge"`
+	Username       string      `json:"username"`
+}
+
+// AccountAge is how long the user has been scrobbling, as whole years and the days after them
+type AccountAge struct {
+	RegisteredAt time.Time `json:"registeredAt"`
+	Years        int       `json:"years"`
+	Days         int       `json:"days"`
+}
+
+// NewAccountAge measures the time from registeredAt to now in calendar years and days
+func NewAccountAge(registeredAt, now time.Time) *AccountAge {
+	registeredAt = registeredAt.UTC()
+	now = now.UTC()
+
+	years := now.Year() - registeredAt.Year()
+	if registeredAt.AddDate(years, 0, 0).After(now) {
+		years--
+	}
+	anniversary := registeredAt.AddDate(years, 0, 0)
+
+	return &AccountAge{
+		RegisteredAt: registeredAt,
+		Years:        years,
+		Days:         int(now.Sub(anniversary) / (24 * time.Hour)),
+	}
 }
 
 func NewService(cfg *config.Config) *Service {
+	return NewServiceWithClient(cfg, NewClient(DefaultBaseURL, cfg.LastFMAPIKey, nil))
+}
+
+// NewServiceWithClient lets callers point the service at another API host or HTTP client
+func NewServiceWithClient(cfg *config.Config, client *Client) *Service {
 	return &Service{
-		config: cfg,
+		config:   cfg,
+		client:   client,
+		username: cfg.LastFMUsername,
 	}
 }
 
-func (s *Service) GetCurrentlyPlaying() (*NowPlayingInfo, error) {
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.getrecenttracks&user=%s&api_key=%s&format=json&limit=1",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-	)
+// ForUser returns a service reading username's data. It shares this service's client, so
+// retries and album art rewriting behave the same for every user.
+func (s *Service) ForUser(username string) *Service {
+	user := *s
+	user.username = username
+	return &user
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
-	}
-	defer resp.Body.Close()
+// Username returns the Last.fm user this service reads
+func (s *Service) Username() string {
+	return s.username
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
-	}
+// userParams returns the query parameters shared by every user.* method
+func (s *Service) userParams() url.Values {
+	return url.Values{"user": {s.username}}
+}
+
+func (s *Service) GetCurrentlyPlaying(ctx context.Context) (*NowPlayingInfo, error) {
+	params := s.userParams()
+	params.Set("limit", "1")
 
 	var lastfmResp recentTracksResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := s.client.Call(ctx, "user.getrecenttracks", params, &lastfmResp); err != nil {
+		return nil, err
 	}
 
 	info := &NowPlayingInfo{
@@ -203,135 +287,74 @@ func (s *Service) GetCurrentlyPlaying() (*NowPlayingInfo, error) {
 	isNowPlaying := track.Attr.NowPlaying == "true"
 	info.IsPlaying = isNowPlaying
 
-	albumArt := ""
-	for _, img := range track.Image {
-		if img.Size == "extralarge" || img.Size == "large" {
-			albumArt = img.Text
-			break
-		}
-	}
-
-	if albumArt == "" && len(track.Image) > 0 {
-		albumArt = track.Image[len(track.Image)-1].Text
-	}
-
 	info.SongName = track.Name
 	info.ArtistName = track.Artist.Text
 	info.AlbumName = track.Album.Text
-	info.AlbumArt = albumArt
+	info.AlbumArt = s.albumArt(track.Image)
 	info.SongURL = track.URL
 
-	if !isNowPlaying && track.Date.UTS != "" {
-		timestamp, err := strconv.ParseInt(track.Date.UTS, 10, 64)
-		if err == nil {
-			info.PlayedAt = time.Unix(timestamp, 0).Format(time.RFC3339)
-		}
+	if !isNowPlaying {
+		info.PlayedAt = formatUTS(track.Date.UTS)
 	}
 
 	return info, nil
 }
 
 // limit: number of artists to return (default: 10, max: 50)
-func (s *Service) GetTopWeeklyArtists(limit int) (*TopArtistsInfo, error) {
-	if limit <= 0 {
-		limit = 10
-	}
-	if limit > 50 {
-		limit = 50
-	}
-
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.gettopartists&user=%s&api_key=%s&format=json&period=7day&limit=%d",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-		limit,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
-	}
-
-	var lastfmResp topArtistsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+func (s *Service) GetTopWeeklyArtists(ctx context.Context, limit int) (*TopArtistsInfo, error) {
+	return s.GetTopArtists(ctx, limit, "7day")
+}
 
-	info := &TopArtistsInfo{
-		Artists: make([]TopArtist, 0),
-	}
+// periods maps every accepted period, Last.fm's own and their aliases, to the Last.fm period
+var periods = map[string]string{
+	"weekly":  "7day",
+	"7day":    "7day",
+	"monthly": "1month",
+	"1month":  "1month",
+	"3month":  "3month",
+	"6month":  "6month",
+	"yearly":  "12month",
+	"12month": "12month",
+	"alltime": "overall",
+	"overall": "overall",
+}
 
-	for _, artist := range lastfmResp.TopArtists.Artist {
-		info.Artists = append(info.Artists, TopArtist{
-			Name:      artist.Name,
-			PlayCount: artist.PlayCount,
-		})
-	}
+// Periods lists the period values the top-* endpoints accept
+var Periods = []string{"7day", "1month", "3month", "6month", "12month", "overall", "weekly", "monthly", "yearly", "alltime"}
 
-	return info, nil
+// ValidPeriod reports whether period is one of Periods
+func ValidPeriod(period string) bool {
+	_, ok := periods[period]
+	return ok
 }
 
 // Accepts: 7day, 1month, 3month, 6month, 12month, overall (default: 7day)
 func validatePeriod(period string) string {
-	validPeriods := map[string]string{
-		"weekly":  "7day",
-		"7day":    "7day",
-		"monthly": "1month",
-		"1month":  "1month",
-		"3month":  "3month",
-		"6month":  "6month",
-		"yearly":  "12month",
-		"12month": "12month",
-		"alltime": "overall",
-		"overall": "overall",
-	}
-
-	if val, ok := validPeriods[period]; ok {
+	if val, ok := periods[period]; ok {
 		return val
 	}
 	return "7day"
 }
 
-func (s *Service) GetTopArtists(limit int, period string) (*TopArtistsInfo, error) {
+// clampLimit applies the default of 10 and Last.fm's page size cap of 50
+func clampLimit(limit int) int {
 	if limit <= 0 {
-		limit = 10
+		return 10
 	}
 	if limit > 50 {
-		limit = 50
+		return 50
 	}
+	return limit
+}
 
-	period = validatePeriod(period)
-
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.gettopartists&user=%s&api_key=%s&format=json&period=%s&limit=%d",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-		period,
-		limit,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
-	}
+func (s *Service) GetTopArtists(ctx context.Context, limit int, period string) (*TopArtistsInfo, error) {
+	params := s.userParams()
+	params.Set("period", validatePeriod(period))
+	params.Set("limit", strconv.Itoa(clampLimit(limit)))
 
 	var lastfmResp topArtistsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := s.client.Call(ctx, "user.gettopartists", params, &lastfmResp); err != nil {
+		return nil, err
 	}
 
 	info := &TopArtistsInfo{
@@ -348,39 +371,14 @@ func (s *Service) GetTopArtists(limit int, period string) (*TopArtistsInfo, erro
 	return info, nil
 }
 
-func (s *Service) GetTopTracks(limit int, period string) (*TopTracksInfo, error) {
-	if limit <= 0 {
-		limit = 10
-	}
-	if limit > 50 {
-		limit = 50
-	}
-
-	period = validatePeriod(period)
-
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.gettoptracks&user=%s&api_key=%s&format=json&period=%s&limit=%d",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-		period,
-		limit,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
-	}
+func (s *Service) GetTopTracks(ctx context.Context, limit int, period string) (*TopTracksInfo, error) {
+	params := s.userParams()
+	params.Set("period", validatePeriod(period))
+	params.Set("limit", strconv.Itoa(clampLimit(limit)))
 
 	var lastfmResp topTracksResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := s.client.Call(ctx, "user.gettoptracks", params, &lastfmResp); err != nil {
+		return nil, err
 	}
 
 	info := &TopTracksInfo{
@@ -388,22 +386,11 @@ func (s *Service) GetTopTracks(limit int, period string) (*TopTracksInfo, error)
 	}
 
 	for _, track := range lastfmResp.TopTracks.Track {
-		albumArt := ""
-		for _, img := range track.Image {
-			if img.Size == "extralarge" || img.Size == "large" {
-				albumArt = img.Text
-				break
-			}
-		}
-		if albumArt == "" && len(track.Image) > 0 {
-			albumArt = track.Image[len(track.Image)-1].Text
-		}
-
 		info.Tracks = append(info.Tracks, TopTrack{
 			Name:      track.Name,
 			Artist:    track.Artist.Name,
 			PlayCount: track.PlayCount,
-			AlbumArt:  albumArt,
+			AlbumArt:  s.albumArt(track.Image),
 			URL:       track.URL,
 		})
 	}
@@ -411,39 +398,14 @@ func (s *Service) GetTopTracks(limit int, period string) (*TopTracksInfo, error)
 	return info, nil
 }
 
-func (s *Service) GetTopAlbums(limit int, period string) (*TopAlbumsInfo, error) {
-	if limit <= 0 {
-		limit = 10
-	}
-	if limit > 50 {
-		limit = 50
-	}
-
-	period = validatePeriod(period)
-
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.gettopalbums&user=%s&api_key=%s&format=json&period=%s&limit=%d",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-		period,
-		limit,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
-	}
+func (s *Service) GetTopAlbums(ctx context.Context, limit int, period string) (*TopAlbumsInfo, error) {
+	params := s.userParams()
+	params.Set("period", validatePeriod(period))
+	params.Set("limit", strconv.Itoa(clampLimit(limit)))
 
 	var lastfmResp topAlbumsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := s.client.Call(ctx, "user.gettopalbums", params, &lastfmResp); err != nil {
+		return nil, err
 	}
 
 	info := &TopAlbumsInfo{
@@ -451,22 +413,11 @@ func (s *Service) GetTopAlbums(limit int, period string) (*TopAlbumsInfo, error)
 	}
 
 	for _, album := range lastfmResp.TopAlbums.Album {
-		albumArt := ""
-		for _, img := range album.Image {
-			if img.Size == "extralarge" || img.Size == "large" {
-				albumArt = img.Text
-				break
-			}
-		}
-		if albumArt == "" && len(album.Image) > 0 {
-			albumArt = album.Image[len(album.Image)-1].Text
-		}
-
 		info.Albums = append(info.Albums, TopAlbum{
 			Name:      album.Name,
 			Artist:    album.Artist.Name,
 			PlayCount: album.PlayCount,
-			AlbumArt:  albumArt,
+			AlbumArt:  s.albumArt(album.Image),
 			URL:       album.URL,
 		})
 	}
@@ -474,36 +425,13 @@ func (s *Service) GetTopAlbums(limit int, period string) (*TopAlbumsInfo, error)
 	return info, nil
 }
 
-func (s *Service) GetRecentTracks(limit int) (*RecentTracksInfo, error) {
-	if limit <= 0 {
-		limit = 10
-	}
-	if limit > 50 {
-		limit = 50
-	}
-
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.getrecenttracks&user=%s&api_key=%s&format=json&limit=%d",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-		limit,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
-	}
+func (s *Service) GetRecentTracks(ctx context.Context, limit int) (*RecentTracksInfo, error) {
+	params := s.userParams()
+	params.Set("limit", strconv.Itoa(clampLimit(limit)))
 
 	var lastfmResp recentTracksResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := s.client.Call(ctx, "user.getrecenttracks", params, &lastfmResp); err != nil {
+		return nil, err
 	}
 
 	info := &RecentTracksInfo{
@@ -511,31 +439,17 @@ func (s *Service) GetRecentTracks(limit int) (*RecentTracksInfo, error) {
 	}
 
 	for _, track := range lastfmResp.RecentTracks.Track {
-		albumArt := ""
-		for _, img := range track.Image {
-			if img.Size == "extralarge" || img.Size == "large" {
-				albumArt = img.Text
-				break
-			}
-		}
-		if albumArt == "" && len(track.Image) > 0 {
-			albumArt = track.Image[len(track.Image)-1].Text
-		}
-
 		isPlaying := track.Attr.NowPlaying == "true"
 		playedAt := ""
-		if !isPlaying && track.Date.UTS != "" {
-			timestamp, err := strconv.ParseInt(track.Date.UTS, 10, 64)
-			if err == nil {
-				playedAt = time.Unix(timestamp, 0).Format(time.RFC3339)
-			}
+		if !isPlaying {
+			playedAt = formatUTS(track.Date.UTS)
 		}
 
 		info.Tracks = append(info.Tracks, RecentTrack{
 			Name:      track.Name,
 			Artist:    track.Artist.Text,
 			Album:     track.Album.Text,
-			AlbumArt:  albumArt,
+			AlbumArt:  s.albumArt(track.Image),
 			URL:       track.URL,
 			PlayedAt:  playedAt,
 			IsPlaying: isPlaying,
@@ -545,37 +459,71 @@ func (s *Service) GetRecentTracks(limit int) (*RecentTracksInfo, error) {
 	return info, nil
 }
 
-func (s *Service) GetListeningStats() (*ListeningStats, error) {
-	apiURL := fmt.Sprintf(
-		"https://ws.audioscrobbler.com/2.0/?method=user.getinfo&user=%s&api_key=%s&format=json",
-		url.QueryEscape(s.config.LastFMUsername),
-		url.QueryEscape(s.config.LastFMAPIKey),
-	)
+// GetRecentTracksPage pages through the user's scrobble history between from and to (either may
+// be zero to leave it open), newest first. limit is capped at Last.fm's maximum of 200.
+func (s *Service) GetRecentTracksPage(ctx context.Context, page, limit int, from, to time.Time) (*RecentTracksPage, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 200
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Last.fm: %w", err)
+	params := s.userParams()
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(limit))
+	if !from.IsZero() {
+		params.Set("from", strconv.FormatInt(from.Unix(), 10))
+	}
+	if !to.IsZero() {
+		params.Set("to", strconv.FormatInt(to.Unix(), 10))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("last.fm api error %d: %s", resp.StatusCode, string(body))
+	var lastfmResp recentTracksResponse
+	if err := s.client.Call(ctx, "user.getrecenttracks", params, &lastfmResp); err != nil {
+		return nil, err
 	}
 
-	var lastfmResp userInfoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lastfmResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	attr := lastfmResp.RecentTracks.Attr
+	result := &RecentTracksPage{
+		Scrobbles: make([]Scrobble, 0, len(lastfmResp.RecentTracks.Track)),
 	}
+	result.Page, _ = strconv.Atoi(attr.Page)
+	result.TotalPages, _ = strconv.Atoi(attr.TotalPages)
+	result.Total, _ = strconv.Atoi(attr.Total)
 
-	accountAge := ""
-	if lastfmResp.User.Registered.UnixTime != "" {
-		timestamp, err := strconv.ParseInt(lastfmResp.User.Registered.UnixTime, 10, 64)
-		if err == nil {
-			regDate := time.Unix(timestamp, 0)
-			accountAge = time.Since(regDate).Round(24 * time.Hour).String()
+	for _, track := range lastfmResp.RecentTracks.Track {
+		if track.Attr.NowPlaying == "true" || track.Date.UTS == "" {
+			continue
 		}
+
+		timestamp, err := strconv.ParseInt(track.Date.UTS, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		result.Scrobbles = append(result.Scrobbles, Scrobble{
+			Track:    track.Name,
+			Artist:   track.Artist.Text,
+			Album:    track.Album.Text,
+			AlbumArt: s.albumArt(track.Image),
+			URL:      track.URL,
+			PlayedAt: time.Unix(timestamp, 0).UTC(),
+		})
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetListeningStats(ctx context.Context) (*ListeningStats, error) {
+	var lastfmResp userInfoResponse
+	if err := s.client.Call(ctx, "user.getinfo", s.userParams(), &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	var accountAge *AccountAge
+	if timestamp, err := strconv.ParseInt(lastfmResp.User.Registered.UnixTime, 10, 64); err == nil {
+		accountAge = NewAccountAge(time.Unix(timestamp, 0), time.Now())
 	}
 
 	return &ListeningStats{
@@ -584,3 +532,44 @@ func (s *Service) GetListeningStats() (*ListeningStats, error) {
 		Username:       lastfmResp.User.Name,
 	}, nil
 }
+
+// SetAlbumArtRewriter makes the service pass every album art URL through fn, e.g. to point
+// them at a local proxy. It must be called before the service is used.
+func (s *Service) SetAlbumArtRewriter(fn func(string) string) {
+	s.rewriteArt = fn
+}
+
+func (s *Service) albumArt(images []image) string {
+	art := pickAlbumArt(images)
+	if s.rewriteArt != nil {
+		return s.rewriteArt(art)
+	}
+	return art
+}
+
+// pickAlbumArt prefers the extralarge or large image, falling back to the last one listed
+func pickAlbumArt(images []image) string {
+	for _, img := range images {
+		if img.Size == "extralarge" || img.Size == "large" {
+			return img.Text
+		}
+	}
+
+	if len(images) > 0 {
+		return images[len(images)-1].Text
+	}
+	return ""
+}
+
+// formatUTS converts a Last.fm unix timestamp string to RFC3339, or "" if it is missing
+func formatUTS(uts string) string {
+	if uts == "" {
+		return ""
+	}
+
+	timestamp, err := strconv.ParseInt(uts, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.Unix(timestamp, 0).Format(time.RFC3339)
+}
@@ -0,0 +1,537 @@
+package lastfm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/config"
+)
+
+// fixture is a recorded Last.fm response in testdata, served with status (200 when zero)
+type fixture struct {
+	file   string
+	status int
+}
+
+// recorder keeps the query of every request the fixture server received
+type recorder struct {
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+func (r *recorder) all() []url.Values {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]url.Values(nil), r.queries...)
+}
+
+// last returns the query of the most recent call to method
+func (r *recorder) last(t *testing.T, method string) url.Values {
+	t.Helper()
+	queries := r.all()
+	for i := len(queries) - 1; i >= 0; i-- {
+		if queries[i].Get("method") == method {
+			return queries[i]
+		}
+	}
+	t.Fatalf("%s was never called", method)
+	return nil
+}
+
+func serveFixture(t *testing.T, w http.ResponseWriter, f fixture) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", f.file))
+	if err != nil {
+		t.Errorf("reading fixture: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if strings.HasSuffix(f.file, ".json") {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+	}
+	w.Write(body)
+}
+
+// newFixtureServer starts a fake Last.fm API answering each method with its fixture and
+// returns a service pointed at it. Calls to any other method fail the test.
+func newFixtureServer(t *testing.T, fixtures map[string]fixture) (*Service, *recorder) {
+	t.Helper()
+
+	rec := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		rec.mu.Lock()
+		rec.queries = append(rec.queries, query)
+		rec.mu.Unlock()
+
+		f, ok := fixtures[query.Get("method")]
+		if !ok {
+			t.Errorf("unexpected call to %s", query.Get("method"))
+			f = fixture{file: "error_operation_failed.json", status: http.StatusInternalServerError}
+		}
+		serveFixture(t, w, f)
+	}))
+	t.Cleanup(server.Close)
+
+	return newTestService(server), rec
+}
+
+// newTestService points a service at server without retries, so error cases fail fast
+func newTestService(server *httptest.Server) *Service {
+	cfg := &config.Config{LastFMUsername: "tringl", LastFMAPIKey: "test-key"}
+	client := NewClient(server.URL+"/2.0/", cfg.LastFMAPIKey, server.Client())
+	client.MaxRetries = 0
+	return NewServiceWithClient(cfg, client)
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("%q is not RFC3339: %v", value, err)
+	}
+	return parsed
+}
+
+func TestGetCurrentlyPlaying(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		wantPlaying bool
+		wantSong    string
+		wantArt     string
+		// Unix time of the play, 0 when PlayedAt should be empty
+		wantPlayedAt int64
+	}{
+		{
+			name:        "now playing",
+			file:        "recenttracks.json",
+			wantPlaying: true,
+			wantSong:    "Roygbiv",
+			wantArt:     "https://lastfm.freetls.fastly.net/i/u/174s/roygbiv.jpg",
+		},
+		{
+			name:         "last played, single track sent as an object",
+			file:         "recenttracks_single.json",
+			wantSong:     "Xtal",
+			wantArt:      "https://lastfm.freetls.fastly.net/i/u/300x300/xtal.jpg",
+			wantPlayedAt: 1700000000,
+		},
+		{
+			name: "no scrobbles",
+			file: "recenttracks_empty.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, rec := newFixtureServer(t, map[string]fixture{
+				"user.getrecenttracks": {file: tt.file},
+			})
+
+			info, err := service.GetCurrentlyPlaying(context.Background())
+			if err != nil {
+				t.Fatalf("GetCurrentlyPlaying: %v", err)
+			}
+			if info.IsPlaying != tt.wantPlaying || info.SongName != tt.wantSong || info.AlbumArt != tt.wantArt {
+				t.Errorf("unexpected now playing info: %+v", info)
+			}
+			if tt.wantPlayedAt == 0 {
+				if info.PlayedAt != "" {
+					t.Errorf("PlayedAt = %q, want empty", info.PlayedAt)
+				}
+			} else if got := mustParseTime(t, info.PlayedAt); got.Unix() != tt.wantPlayedAt {
+				t.Errorf("PlayedAt = %v, want unix %d", got, tt.wantPlayedAt)
+			}
+			mustParseTime(t, info.LastUpdated)
+
+			query := rec.last(t, "user.getrecenttracks")
+			if query.Get("user") != "tringl" || query.Get("limit") != "1" ||
+				query.Get("api_key") != "test-key" || query.Get("format") != "json" {
+				t.Errorf("unexpected query: %v", query)
+			}
+		})
+	}
+}
+
+func TestGetRecentTracks(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getrecenttracks": {file: "recenttracks.json"},
+	})
+
+	info, err := service.GetRecentTracks(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("GetRecentTracks: %v", err)
+	}
+	if len(info.Tracks) != 3 {
+		t.Fatalf("got %d tracks, want 3", len(info.Tracks))
+	}
+
+	playing, played, noArt := info.Tracks[0], info.Tracks[1], info.Tracks[2]
+	if !playing.IsPlaying || playing.PlayedAt != "" {
+		t.Errorf("first track should be playing without PlayedAt: %+v", playing)
+	}
+	if played.IsPlaying || played.Artist != "Aphex Twin" || played.Album != "Selected Ambient Works 85-92" {
+		t.Errorf("unexpected second track: %+v", played)
+	}
+	if got := mustParseTime(t, played.PlayedAt); got.Unix() != 1700000000 {
+		t.Errorf("PlayedAt = %v, want unix 1700000000", got)
+	}
+	if played.AlbumArt != "https://lastfm.freetls.fastly.net/i/u/64s/xtal.jpg" {
+		t.Errorf("AlbumArt = %q, want the last image when there is no large one", played.AlbumArt)
+	}
+	if noArt.AlbumArt != "" || noArt.Album != "" {
+		t.Errorf("unexpected third track: %+v", noArt)
+	}
+	if got := rec.last(t, "user.getrecenttracks").Get("limit"); got != "3" {
+		t.Errorf("limit = %q, want 3", got)
+	}
+}
+
+func TestGetRecentTracksLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  string
+	}{
+		{0, "10"},
+		{-5, "10"},
+		{25, "25"},
+		{500, "50"},
+	}
+
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getrecenttracks": {file: "recenttracks_empty.json"},
+	})
+	for _, tt := range tests {
+		info, err := service.GetRecentTracks(context.Background(), tt.limit)
+		if err != nil {
+			t.Fatalf("GetRecentTracks(%d): %v", tt.limit, err)
+		}
+		if info.Tracks == nil || len(info.Tracks) != 0 {
+			t.Errorf("expected an empty, non-nil list, got %#v", info.Tracks)
+		}
+		if got := rec.last(t, "user.getrecenttracks").Get("limit"); got != tt.want {
+			t.Errorf("GetRecentTracks(%d) limit = %q, want %q", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestGetRecentTracksPage(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getrecenttracks": {file: "recenttracks.json"},
+	})
+
+	from := time.Unix(1690000000, 0)
+	to := time.Unix(1700000001, 0)
+	page, err := service.GetRecentTracksPage(context.Background(), 1, 500, from, to)
+	if err != nil {
+		t.Fatalf("GetRecentTracksPage: %v", err)
+	}
+
+	if page.Page != 1 || page.TotalPages != 42 || page.Total != 125 {
+		t.Errorf("unexpected paging: page %d of %d, total %d", page.Page, page.TotalPages, page.Total)
+	}
+	// The now playing track has no date and is left out
+	if len(page.Scrobbles) != 2 {
+		t.Fatalf("got %d scrobbles, want 2", len(page.Scrobbles))
+	}
+	first := page.Scrobbles[0]
+	if first.Track != "Xtal" || first.PlayedAt.Unix() != 1700000000 || first.PlayedAt.Location() != time.UTC {
+		t.Errorf("unexpected first scrobble: %+v", first)
+	}
+
+	query := rec.last(t, "user.getrecenttracks")
+	want := map[string]string{"page": "1", "limit": "200", "from": "1690000000", "to": "1700000001"}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	if _, err := service.GetRecentTracksPage(context.Background(), 0, 50, time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("GetRecentTracksPage with open range: %v", err)
+	}
+	query = rec.last(t, "user.getrecenttracks")
+	if query.Has("from") || query.Has("to") || query.Get("page") != "1" {
+		t.Errorf("open range should send neither from nor to and start at page 1: %v", query)
+	}
+}
+
+func TestGetTopArtists(t *testing.T) {
+	tests := []struct {
+		file string
+		want []TopArtist
+	}{
+		{"topartists.json", []TopArtist{{Name: "Burial", PlayCount: "420"}, {Name: "Four Tet", PlayCount: "170"}}},
+		{"topartists_single.json", []TopArtist{{Name: "Burial", PlayCount: "420"}}},
+		{"topartists_empty.json", []TopArtist{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			service, _ := newFixtureServer(t, map[string]fixture{
+				"user.gettopartists": {file: tt.file},
+			})
+
+			info, err := service.GetTopArtists(context.Background(), 10, "7day")
+			if err != nil {
+				t.Fatalf("GetTopArtists: %v", err)
+			}
+			if info.Artists == nil || len(info.Artists) != len(tt.want) {
+				t.Fatalf("got %#v, want %#v", info.Artists, tt.want)
+			}
+			for i := range tt.want {
+				if info.Artists[i].Name != tt.want[i].Name || info.Artists[i].PlayCount != tt.want[i].PlayCount {
+					t.Errorf("artist %d = %+v, want %+v", i, info.Artists[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTopPeriods(t *testing.T) {
+	tests := []struct {
+		period string
+		want   string
+	}{
+		{"7day", "7day"},
+		{"weekly", "7day"},
+		{"monthly", "1month"},
+		{"3month", "3month"},
+		{"yearly", "12month"},
+		{"alltime", "overall"},
+		{"", "7day"},
+		{"fortnight", "7day"},
+	}
+
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.gettopartists": {file: "topartists_empty.json"},
+	})
+	for _, tt := range tests {
+		if _, err := service.GetTopArtists(context.Background(), 10, tt.period); err != nil {
+			t.Fatalf("GetTopArtists(%q): %v", tt.period, err)
+		}
+		if got := rec.last(t, "user.gettopartists").Get("period"); got != tt.want {
+			t.Errorf("period %q sent as %q, want %q", tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestGetTopTracks(t *testing.T) {
+	tests := []struct {
+		file     string
+		wantLen  int
+		wantArt  string
+		wantName string
+	}{
+		{"toptracks.json", 3, "https://lastfm.freetls.fastly.net/i/u/174s/archangel.jpg", "Archangel"},
+		{"toptracks_single.json", 1, "https://lastfm.freetls.fastly.net/i/u/64s/archangel.jpg", "Archangel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			service, _ := newFixtureServer(t, map[string]fixture{
+				"user.gettoptracks": {file: tt.file},
+			})
+
+			info, err := service.GetTopTracks(context.Background(), 10, "overall")
+			if err != nil {
+				t.Fatalf("GetTopTracks: %v", err)
+			}
+			if len(info.Tracks) != tt.wantLen {
+				t.Fatalf("got %d tracks, want %d", len(info.Tracks), tt.wantLen)
+			}
+			track := info.Tracks[0]
+			if track.Name != tt.wantName || track.Artist != "Burial" || track.PlayCount != "93" ||
+				track.AlbumArt != tt.wantArt || track.URL != "https://www.last.fm/music/Burial/_/Archangel" || track.Loved {
+				t.Errorf("unexpected track: %+v", track)
+			}
+		})
+	}
+}
+
+func TestGetTopAlbums(t *testing.T) {
+	tests := []struct {
+		file string
+		want []TopAlbum
+	}{
+		{"topalbums.json", []TopAlbum{{
+			Name:      "Untrue",
+			Artist:    "Burial",
+			PlayCount: "300",
+			AlbumArt:  "https://lastfm.freetls.fastly.net/i/u/174s/untrue.jpg",
+			URL:       "https://www.last.fm/music/Burial/Untrue",
+		}}},
+		{"topalbums_empty.json", []TopAlbum{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			service, _ := newFixtureServer(t, map[string]fixture{
+				"user.gettopalbums": {file: tt.file},
+			})
+
+			info, err := service.GetTopAlbums(context.Background(), 10, "1month")
+			if err != nil {
+				t.Fatalf("GetTopAlbums: %v", err)
+			}
+			if info.Albums == nil || len(info.Albums) != len(tt.want) {
+				t.Fatalf("got %#v, want %#v", info.Albums, tt.want)
+			}
+			for i := range tt.want {
+				if info.Albums[i] != tt.want[i] {
+					t.Errorf("album %d = %+v, want %+v", i, info.Albums[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetListeningStats(t *testing.T) {
+	service, _ := newFixtureServer(t, map[string]fixture{
+		"user.getinfo": {file: "userinfo.json"},
+	})
+
+	stats, err := service.GetListeningStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetListeningStats: %v", err)
+	}
+	if stats.TotalScrobbles != "54321" || stats.Username != "tringl" || stats.AccountAge == nil {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if want := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC); !stats.AccountAge.RegisteredAt.Equal(want) {
+		t.Errorf("RegisteredAt = %v, want %v", stats.AccountAge.RegisteredAt, want)
+	}
+}
+
+func TestForUserAndAlbumArtRewriter(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.gettoptracks": {file: "toptracks_single.json"},
+	})
+	service.SetAlbumArtRewriter(func(art string) string {
+		return "/api/art/" + strings.TrimPrefix(art, "https://lastfm.freetls.fastly.net/i/u/")
+	})
+
+	friend := service.ForUser("friend")
+	info, err := friend.GetTopTracks(context.Background(), 1, "7day")
+	if err != nil {
+		t.Fatalf("GetTopTracks: %v", err)
+	}
+	if got := info.Tracks[0].AlbumArt; got != "/api/art/64s/archangel.jpg" {
+		t.Errorf("AlbumArt = %q, want it rewritten", got)
+	}
+	if got := rec.last(t, "user.gettoptracks").Get("user"); got != "friend" {
+		t.Errorf("user = %q, want friend", got)
+	}
+	if friend.Username() != "friend" || service.Username() != "tringl" {
+		t.Errorf("ForUser changed the wrong service: %q, %q", friend.Username(), service.Username())
+	}
+}
+
+func TestPickAlbumArt(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []image
+		want   string
+	}{
+		{
+			name: "large listed before extralarge",
+			images: []image{
+				{Size: "small", Text: "s.jpg"},
+				{Size: "medium", Text: "m.jpg"},
+				{Size: "large", Text: "l.jpg"},
+				{Size: "extralarge", Text: "xl.jpg"},
+			},
+			want: "l.jpg",
+		},
+		{
+			name:   "extralarge only",
+			images: []image{{Size: "small", Text: "s.jpg"}, {Size: "extralarge", Text: "xl.jpg"}},
+			want:   "xl.jpg",
+		},
+		{
+			name:   "falls back to the last image",
+			images: []image{{Size: "small", Text: "s.jpg"}, {Size: "medium", Text: "m.jpg"}},
+			want:   "m.jpg",
+		},
+		{
+			name:   "empty large image is still chosen",
+			images: []image{{Size: "large", Text: ""}, {Size: "mega", Text: "mega.jpg"}},
+			want:   "",
+		},
+		{
+			name: "no images",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickAlbumArt(tt.images); got != tt.want {
+				t.Errorf("pickAlbumArt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatUTS(t *testing.T) {
+	tests := []struct {
+		uts  string
+		want int64
+		// Whether a timestamp is expected at all
+		ok bool
+	}{
+		{"1700000000", 1700000000, true},
+		{"0", 0, true},
+		{"", 0, false},
+		{"yesterday", 0, false},
+	}
+
+	for _, tt := range tests {
+		got := formatUTS(tt.uts)
+		if !tt.ok {
+			if got != "" {
+				t.Errorf("formatUTS(%q) = %q, want empty", tt.uts, got)
+			}
+			continue
+		}
+		if parsed := mustParseTime(t, got); parsed.Unix() != tt.want {
+			t.Errorf("formatUTS(%q) = %q, want unix %d", tt.uts, got, tt.want)
+		}
+	}
+}
+
+func TestNewAccountAge(t *testing.T) {
+	registered := time.Date(2010, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantYears int
+		wantDays  int
+	}{
+		{"same day", registered, 0, 0},
+		{"day before anniversary", time.Date(2011, 3, 14, 12, 0, 0, 0, time.UTC), 0, 364},
+		{"anniversary", time.Date(2011, 3, 15, 12, 0, 0, 0, time.UTC), 1, 0},
+		{"years and days", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), 15, 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			age := NewAccountAge(registered, tt.now)
+			if age.Years != tt.wantYears || age.Days != tt.wantDays {
+				t.Errorf("got %d years %d days, want %d years %d days", age.Years, age.Days, tt.wantYears, tt.wantDays)
+			}
+		})
+	}
+}
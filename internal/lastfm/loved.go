@@ -0,0 +1,88 @@
+package lastfm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+type lovedTracksResponse struct {
+	LovedTracks struct {
+		Track oneOrMany[struct {
+			Name   string `json:"name"`
+			URL    string `json:"url"`
+			Artist struct {
+				Name string `json:"name"`
+			} `json:"artist"`
+			Date struct {
+				UTS string `json:"uts"`
+			} `json:"date"`
+			Image []image `json:"image"`
+		}] `json:"track"`
+		Attr struct {
+			Page       string `json:"page"`
+			TotalPages string `json:"totalPages"`
+			Total      string `json:"total"`
+		} `json:"@attr"`
+	} `json:"lovedtracks"`
+}
+
+type LovedTrack struct {
+	Name     string `json:"name"`
+	Artist   string `json:"artist"`
+	AlbumArt string `json:"albumArt,omitempty"`
+	URL      string `json:"url"`
+	LovedAt  string `json:"lovedAt"`
+}
+
+type LovedTracksInfo struct {
+	Tracks     []LovedTrack `json:"tracks"`
+	Page       int          `json:"page"`
+	TotalPages int          `json:"totalPages"`
+	Total      int          `json:"total"`
+}
+
+// GetLovedTracks returns one page of the user's loved tracks, most recently loved first.
+// limit is capped at 200.
+func (s *Service) GetLovedTracks(ctx context.Context, page, limit int) (*LovedTracksInfo, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	params := s.userParams()
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(limit))
+
+	var lastfmResp lovedTracksResponse
+	if err := s.client.Call(ctx, "user.getlovedtracks", params, &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	attr := lastfmResp.LovedTracks.Attr
+	info := &LovedTracksInfo{
+		Tracks: make([]LovedTrack, 0, len(lastfmResp.LovedTracks.Track)),
+	}
+	info.Page, _ = strconv.Atoi(attr.Page)
+	info.TotalPages, _ = strconv.Atoi(attr.TotalPages)
+	info.Total, _ = strconv.Atoi(attr.Total)
+
+	for _, track := range lastfmResp.LovedTracks.Track {
+		info.Tracks = append(info.Tracks, LovedTrack{
+			Name:     track.Name,
+			Artist:   track.Artist.Name,
+			AlbumArt: s.albumArt(track.Image),
+			URL:      track.URL,
+			LovedAt:  formatUTS(track.Date.UTS),
+		})
+	}
+
+	return info, nil
+}
+
+// lovedKey identifies a track when matching plays against loved tracks, ignoring case
+func lovedKey(artist, track string) string {
+	return strings.ToLower(artist) + "\x1f" + strings.ToLower(track)
+}
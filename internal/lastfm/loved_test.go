@@ -0,0 +1,66 @@
+package lastfm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetLovedTracks(t *testing.T) {
+	tests := []struct {
+		file      string
+		wantLen   int
+		wantPage  int
+		wantTotal int
+	}{
+		{"lovedtracks.json", 2, 2, 6},
+		{"lovedtracks_single.json", 1, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			service, _ := newFixtureServer(t, map[string]fixture{
+				"user.getlovedtracks": {file: tt.file},
+			})
+
+			info, err := service.GetLovedTracks(context.Background(), 1, 50)
+			if err != nil {
+				t.Fatalf("GetLovedTracks: %v", err)
+			}
+			if len(info.Tracks) != tt.wantLen || info.Page != tt.wantPage || info.Total != tt.wantTotal {
+				t.Fatalf("unexpected page: %+v", info)
+			}
+
+			track := info.Tracks[0]
+			if track.Name != "Archangel" || track.Artist != "Burial" {
+				t.Errorf("unexpected track: %+v", track)
+			}
+			if got := mustParseTime(t, track.LovedAt); got.Unix() != 1690000000 {
+				t.Errorf("LovedAt = %v, want unix 1690000000", got)
+			}
+		})
+	}
+}
+
+func TestGetLovedTracksParams(t *testing.T) {
+	tests := []struct {
+		page, limit         int
+		wantPage, wantLimit string
+	}{
+		{0, 0, "1", "50"},
+		{3, 200, "3", "200"},
+		{2, 500, "2", "50"},
+	}
+
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getlovedtracks": {file: "lovedtracks.json"},
+	})
+	for _, tt := range tests {
+		if _, err := service.GetLovedTracks(context.Background(), tt.page, tt.limit); err != nil {
+			t.Fatalf("GetLovedTracks: %v", err)
+		}
+		query := rec.last(t, "user.getlovedtracks")
+		if query.Get("page") != tt.wantPage || query.Get("limit") != tt.wantLimit {
+			t.Errorf("GetLovedTracks(%d, %d) sent page %s limit %s", tt.page, tt.limit, query.Get("page"), query.Get("limit"))
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package lastfm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnsupported is returned for features the configured provider doesn't have
+var ErrUnsupported = errors.New("not supported by this music provider")
+
+// Provider is a source of listening data. Service implements it for Last.fm; other services
+// map their responses into the same types so API responses look alike whichever is used.
+type Provider interface {
+	GetCurrentlyPlaying(ctx context.Context) (*NowPlayingInfo, error)
+	GetRecentTracks(ctx context.Context, limit int) (*RecentTracksInfo, error)
+	GetTopArtists(ctx context.Context, limit int, period string) (*TopArtistsInfo, error)
+	GetTopTracks(ctx context.Context, limit int, period string) (*TopTracksInfo, error)
+	GetTopAlbums(ctx context.Context, limit int, period string) (*TopAlbumsInfo, error)
+	GetListeningStats(ctx context.Context) (*ListeningStats, error)
+}
+
+// lovedTracksProvider is implemented by providers that know which tracks the user loved
+type lovedTracksProvider interface {
+	GetLovedTracks(ctx context.Context, page, limit int) (*LovedTracksInfo, error)
+}
+
+// rangeChartsProvider is implemented by providers with charts for arbitrary date ranges, and
+// so comparisons between them
+type rangeChartsProvider interface {
+	GetTopArtistsRange(ctx context.Context, limit int, from, to time.Time) (*TopArtistsInfo, error)
+	GetTopTracksRange(ctx context.Context, limit int, from, to time.Time) (*TopTracksInfo, error)
+	GetTopAlbumsRange(ctx context.Context, limit int, from, to time.Time) (*TopAlbumsInfo, error)
+	GetArtistTrends(ctx context.Context, limit int, current, previous string) (*Trends, error)
+}
+
+// artistDetailProvider is implemented by providers with artist bios and similar artists
+type artistDetailProvider interface {
+	GetArtistDetail(ctx context.Context, name string) (*ArtistDetail, error)
+}
+
+var _ Provider = (*Service)(nil)
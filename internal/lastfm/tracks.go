@@ -0,0 +1,76 @@
+package lastfm
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type trackInfoResponse struct {
+	Track struct {
+		Name string `json:"name"`
+		// Milliseconds, "0" when Last.fm doesn't know the length
+		Duration string `json:"duration"`
+	} `json:"track"`
+}
+
+// GetTrackDuration returns a track's length from track.getInfo, or 0 when Last.fm doesn't
+// know it
+func (s *Service) GetTrackDuration(ctx context.Context, artist, track string) (time.Duration, error) {
+	params := url.Values{
+		"artist":      {artist},
+		"track":       {track},
+		"autocorrect": {"1"},
+	}
+
+	var lastfmResp trackInfoResponse
+	if err := s.client.Call(ctx, "track.getinfo", params, &lastfmResp); err != nil {
+		return 0, err
+	}
+
+	ms, _ := strconv.ParseInt(lastfmResp.Track.Duration, 10, 64)
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// GetTopTrackPlays returns up to limit (at most 1000, one page) of the period's most played
+// tracks with their play counts
+func (s *Service) GetTopTrackPlays(ctx context.Context, period string, limit int) ([]ChartEntry, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	params := s.userParams()
+	params.Set("period", validatePeriod(period))
+	params.Set("limit", strconv.Itoa(limit))
+
+	var lastfmResp topTracksResponse
+	if err := s.client.Call(ctx, "user.gettoptracks", params, &lastfmResp); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ChartEntry, 0, len(lastfmResp.TopTracks.Track))
+	for _, track := range lastfmResp.TopTracks.Track {
+		plays, _ := strconv.Atoi(track.PlayCount)
+		entries = append(entries, ChartEntry{Name: track.Name, Artist: track.Artist.Name, PlayCount: plays, URL: track.URL})
+	}
+	return entries, nil
+}
+
+// CountScrobbles returns how many plays the user scrobbled since from, or in total when from
+// is zero
+func (s *Service) CountScrobbles(ctx context.Context, from time.Time) (int, error) {
+	if from.IsZero() {
+		stats, err := s.GetListeningStats(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(stats.TotalScrobbles)
+	}
+
+	page, err := s.GetRecentTracksPage(ctx, 1, 1, from, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	return page.Total, nil
+}
@@ -0,0 +1,85 @@
+package lastfm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetTrackDuration(t *testing.T) {
+	tests := []struct {
+		file string
+		want time.Duration
+	}{
+		{"trackinfo.json", 239 * time.Second},
+		{"trackinfo_unknown_length.json", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			service, rec := newFixtureServer(t, map[string]fixture{
+				"track.getinfo": {file: tt.file},
+			})
+
+			got, err := service.GetTrackDuration(context.Background(), "Burial", "Archangel")
+			if err != nil {
+				t.Fatalf("GetTrackDuration: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("duration = %v, want %v", got, tt.want)
+			}
+
+			query := rec.last(t, "track.getinfo")
+			if query.Get("artist") != "Burial" || query.Get("track") != "Archangel" || query.Get("autocorrect") != "1" {
+				t.Errorf("unexpected query: %v", query)
+			}
+		})
+	}
+}
+
+func TestGetTopTrackPlays(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.gettoptracks": {file: "toptracks.json"},
+	})
+
+	entries, err := service.GetTopTrackPlays(context.Background(), "yearly", 0)
+	if err != nil {
+		t.Fatalf("GetTopTrackPlays: %v", err)
+	}
+	want := ChartEntry{Name: "Archangel", Artist: "Burial", PlayCount: 93, URL: "https://www.last.fm/music/Burial/_/Archangel"}
+	if len(entries) != 3 || entries[0] != want {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	query := rec.last(t, "user.gettoptracks")
+	if query.Get("limit") != "1000" || query.Get("period") != "12month" {
+		t.Errorf("unexpected query: %v", query)
+	}
+}
+
+func TestCountScrobbles(t *testing.T) {
+	service, rec := newFixtureServer(t, map[string]fixture{
+		"user.getinfo":         {file: "userinfo.json"},
+		"user.getrecenttracks": {file: "recenttracks.json"},
+	})
+
+	total, err := service.CountScrobbles(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("CountScrobbles overall: %v", err)
+	}
+	if total != 54321 {
+		t.Errorf("overall = %d, want the profile's play count", total)
+	}
+
+	since, err := service.CountScrobbles(context.Background(), time.Unix(1690000000, 0))
+	if err != nil {
+		t.Fatalf("CountScrobbles since: %v", err)
+	}
+	if since != 125 {
+		t.Errorf("since = %d, want the page total", since)
+	}
+	query := rec.last(t, "user.getrecenttracks")
+	if query.Get("limit") != "1" || query.Get("from") != "1690000000" {
+		t.Errorf("unexpected query: %v", query)
+	}
+}
@@ -0,0 +1,167 @@
+package lastfm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TrendPeriods lists the window lengths /api/trends accepts. overall has no window before it.
+var TrendPeriods = []string{"7day", "1month", "3month", "6month", "12month", "weekly", "monthly", "yearly"}
+
+var ErrInvalidTrendPeriod = errors.New("trend periods must be one of 7day, 1month, 3month, 6month or 12month")
+
+const (
+	TrendNew     = "new"
+	TrendUp      = "up"
+	TrendDown    = "down"
+	TrendSame    = "same"
+	TrendDropped = "dropped"
+)
+
+// Trends compares the top artists of two consecutive windows, Previous ending where Current
+// starts
+type Trends struct {
+	Current  ChartRange    `json:"current"`
+	Previous ChartRange    `json:"previous"`
+	Artists  []TrendArtist `json:"artists"`
+}
+
+// TrendArtist is an artist in either window's top list. Ranks are nil when the artist had no
+// plays in that window; RankChange is positive for artists that climbed.
+type TrendArtist struct {
+	Name              string `json:"name"`
+	Rank              *int   `json:"rank"`
+	PreviousRank      *int   `json:"previousRank"`
+	RankChange        int    `json:"rankChange"`
+	PlayCount         int    `json:"playcount"`
+	PreviousPlayCount int    `json:"previousPlaycount"`
+	PlayCountDelta    int    `json:"playcountDelta"`
+	// One of new, up, down, same or dropped, the last for artists that fell out of the top list
+	Status string `json:"status"`
+}
+
+// ValidTrendPeriod reports whether period is one of TrendPeriods
+func ValidTrendPeriod(period string) bool {
+	_, ok := PeriodStart(period, time.Now())
+	return ok
+}
+
+// PeriodStart returns when a window of period ending at end begins. It is false for overall,
+// which has no start.
+func PeriodStart(period string, end time.Time) (time.Time, bool) {
+	switch periods[period] {
+	case "7day":
+		return end.AddDate(0, 0, -7), true
+	case "1month":
+		return end.AddDate(0, -1, 0), true
+	case "3month":
+		return end.AddDate(0, -3, 0), true
+	case "6month":
+		return end.AddDate(0, -6, 0), true
+	case "12month":
+		return end.AddDate(-1, 0, 0), true
+	}
+	return time.Time{}, false
+}
+
+// GetArtistTrends compares the top limit artists of the current window, ending now, with
+// those of the previous window before it. Both are merged like date ranges, so the week in
+// progress counts towards the current window.
+func (s *Service) GetArtistTrends(ctx context.Context, limit int, current, previous string) (*Trends, error) {
+	return s.artistTrends(ctx, limit, current, previous, time.Now().UTC())
+}
+
+func (s *Service) artistTrends(ctx context.Context, limit int, current, previous string, now time.Time) (*Trends, error) {
+	currentFrom, ok := PeriodStart(current, now)
+	if !ok {
+		return nil, ErrInvalidTrendPeriod
+	}
+	previousFrom, ok := PeriodStart(previous, currentFrom)
+	if !ok {
+		return nil, ErrInvalidTrendPeriod
+	}
+
+	currentChart, err := s.mergedChart(ctx, "artist", currentFrom, now)
+	if err != nil {
+		return nil, err
+	}
+	previousChart, err := s.mergedChart(ctx, "artist", previousFrom, currentFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Trends{
+		Current:  ChartRange{From: currentFrom, To: now},
+		Previous: ChartRange{From: previousFrom, To: currentFrom},
+		Artists:  CompareCharts(currentChart, previousChart, clampLimit(limit)),
+	}, nil
+}
+
+// CompareCharts diffs two artist charts sorted by plays. Artists in the current top limit come
+// first in order, followed by those that were in the previous top limit but no longer are.
+func CompareCharts(current, previous []ChartEntry, limit int) []TrendArtist {
+	type position struct {
+		rank  int
+		plays int
+	}
+	index := func(chart []ChartEntry) map[string]position {
+		positions := make(map[string]position, len(chart))
+		for i, e := range chart {
+			positions[strings.ToLower(e.Name)] = position{rank: i + 1, plays: e.PlayCount}
+		}
+		return positions
+	}
+	currentPositions, previousPositions := index(current), index(previous)
+
+	artists := make([]TrendArtist, 0)
+	for i, e := range topEntries(current, limit) {
+		rank := i + 1
+		artist := TrendArtist{
+			Name:      e.Name,
+			Rank:      &rank,
+			PlayCount: e.PlayCount,
+			Status:    TrendNew,
+		}
+		if before, ok := previousPositions[strings.ToLower(e.Name)]; ok {
+			artist.PreviousRank = &before.rank
+			artist.PreviousPlayCount = before.plays
+			artist.RankChange = before.rank - rank
+			switch {
+			case artist.RankChange > 0:
+				artist.Status = TrendUp
+			case artist.RankChange < 0:
+				artist.Status = TrendDown
+			default:
+				artist.Status = TrendSame
+			}
+		}
+		artist.PlayCountDelta = artist.PlayCount - artist.PreviousPlayCount
+		artists = append(artists, artist)
+	}
+
+	for i, e := range topEntries(previous, limit) {
+		after, ok := currentPositions[strings.ToLower(e.Name)]
+		if ok && after.rank <= limit {
+			continue
+		}
+
+		previousRank := i + 1
+		artist := TrendArtist{
+			Name:              e.Name,
+			PreviousRank:      &previousRank,
+			PreviousPlayCount: e.PlayCount,
+			Status:            TrendDropped,
+		}
+		if ok {
+			artist.Rank = &after.rank
+			artist.PlayCount = after.plays
+			artist.RankChange = previousRank - after.rank
+		}
+		artist.PlayCountDelta = artist.PlayCount - artist.PreviousPlayCount
+		artists = append(artists, artist)
+	}
+
+	return artists
+}
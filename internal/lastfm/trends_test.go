@@ -0,0 +1,111 @@
+package lastfm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// play is a scrobble in the fake history served by newHistoryServer
+type play struct {
+	artist string
+	at     int64
+}
+
+// newHistoryServer fakes Last.fm over a fixed list of plays: the chart list comes from its
+// fixture, and weekly artist charts and recent tracks are computed from plays
+func newHistoryServer(t *testing.T, plays []play) *Service {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from, _ := strconv.ParseInt(query.Get("from"), 10, 64)
+		to, _ := strconv.ParseInt(query.Get("to"), 10, 64)
+
+		switch query.Get("method") {
+		case "user.getweeklychartlist":
+			serveFixture(t, w, fixture{file: "weeklychartlist.json"})
+		case "user.getweeklyartistchart":
+			counts := map[string]int{}
+			for _, p := range plays {
+				if p.at >= from && p.at < to {
+					counts[p.artist]++
+				}
+			}
+			artists := make([]map[string]string, 0, len(counts))
+			for name, n := range counts {
+				artists = append(artists, map[string]string{"name": name, "playcount": strconv.Itoa(n)})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"weeklyartistchart": map[string]any{"artist": artists}})
+		case "user.getrecenttracks":
+			tracks := make([]map[string]any, 0)
+			for _, p := range plays {
+				if p.at >= from && p.at <= to {
+					tracks = append(tracks, map[string]any{
+						"name":   "Track",
+						"artist": map[string]string{"#text": p.artist},
+						"date":   map[string]string{"uts": strconv.FormatInt(p.at, 10)},
+					})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"recenttracks": map[string]any{
+				"track": tracks,
+				"@attr": map[string]string{"page": "1", "totalPages": "1", "total": strconv.Itoa(len(tracks))},
+			}})
+		default:
+			t.Errorf("unexpected call to %s", query.Get("method"))
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return newTestService(server)
+}
+
+func TestArtistTrendsMidWeek(t *testing.T) {
+	// Two days into a week Last.fm hasn't published a chart for yet
+	now := time.Unix(1701172800, 0).UTC()
+	service := newHistoryServer(t, []play{
+		// Current window
+		{"Burial", 1701100000},
+		{"Burial", 1701100300},
+		{"Burial", 1701100600},
+		{"Four Tet", 1700600000},
+		// Previous window
+		{"Four Tet", 1700000000},
+		{"Four Tet", 1700000300},
+		{"Aphex Twin", 1700500000},
+	})
+
+	trends, err := service.artistTrends(context.Background(), 10, "7day", "7day", now)
+	if err != nil {
+		t.Fatalf("artistTrends: %v", err)
+	}
+	if !trends.Current.To.Equal(now) || !trends.Previous.To.Equal(trends.Current.From) {
+		t.Errorf("unexpected windows: %+v, %+v", trends.Current, trends.Previous)
+	}
+
+	want := []struct {
+		name   string
+		status string
+		plays  int
+		before int
+	}{
+		{"Burial", TrendNew, 3, 0},
+		{"Four Tet", TrendDown, 1, 2},
+		{"Aphex Twin", TrendDropped, 0, 1},
+	}
+	if len(trends.Artists) != len(want) {
+		t.Fatalf("got %+v", trends.Artists)
+	}
+	for i, w := range want {
+		got := trends.Artists[i]
+		if got.Name != w.name || got.Status != w.status || got.PlayCount != w.plays || got.PreviousPlayCount != w.before {
+			t.Errorf("artist %d = %+v, want %s %s with %d plays after %d", i, got, w.name, w.status, w.plays, w.before)
+		}
+	}
+}
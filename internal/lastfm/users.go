@@ -0,0 +1,103 @@
+package lastfm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Upstream requests made at once by NowPlayingAll
+	usersConcurrency = 4
+
+	// Per-user limit in NowPlayingAll, so one slow profile doesn't hold up the rest
+	userFetchTimeout = 10 * time.Second
+)
+
+// Users holds a cached service for every allow-listed Last.fm user
+type Users struct {
+	names    []string
+	services map[string]*CachedService
+}
+
+// NewUsers creates a cached service per username from service. primary, if not nil, is used
+// for service's own user so it shares the cache of the single-user endpoints.
+func NewUsers(service *Service, primary *CachedService, usernames []string, ttls CacheTTLs) *Users {
+	u := &Users{services: make(map[string]*CachedService, len(usernames))}
+	for _, name := range usernames {
+		key := strings.ToLower(name)
+		if _, ok := u.services[key]; ok {
+			continue
+		}
+
+		u.names = append(u.names, name)
+		if primary != nil && strings.EqualFold(name, service.Username()) {
+			u.services[key] = primary
+			continue
+		}
+		u.services[key] = NewCachedService(service.ForUser(name), ttls)
+	}
+	return u
+}
+
+// Get returns the service for username. Last.fm usernames are case insensitive, so the
+// lookup is too.
+func (u *Users) Get(username string) (*CachedService, bool) {
+	service, ok := u.services[strings.ToLower(username)]
+	return service, ok
+}
+
+// Names returns the allow-listed usernames in the order they were configured
+func (u *Users) Names() []string {
+	return u.names
+}
+
+// UserNowPlaying is one user's entry in NowPlayingAll. Exactly one of NowPlaying and Error
+// is set.
+type UserNowPlaying struct {
+	Username   string          `json:"username"`
+	NowPlaying *NowPlayingInfo `json:"nowPlaying,omitempty"`
+	Error      string          `json:"error,omitempty"`
+
+	err error
+}
+
+// Err returns the error fetching this user failed with
+func (n UserNowPlaying) Err() error {
+	return n.err
+}
+
+// NowPlayingAll fetches what every user is playing, a few at a time. A failure only affects
+// that user's entry, results keep the configured order.
+func (u *Users) NowPlayingAll(ctx context.Context) []UserNowPlaying {
+	results := make([]UserNowPlaying, len(u.names))
+	sem := make(chan struct{}, usersConcurrency)
+
+	var wg sync.WaitGroup
+	for i, name := range u.names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Each fetch records its own cache status, sharing the caller's would race
+			fetchCtx, cancel := context.WithTimeout(context.WithValue(ctx, cacheStatusKey{}, &CacheStatus{}), userFetchTimeout)
+			defer cancel()
+
+			result := UserNowPlaying{Username: name}
+			nowPlaying, err := u.services[strings.ToLower(name)].GetCurrentlyPlaying(fetchCtx)
+			if err != nil {
+				result.err = err
+				result.Error = "Failed to fetch currently playing song"
+			} else {
+				result.NowPlaying = nowPlaying
+			}
+			results[i] = result
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,413 @@
+package listenbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+)
+
+// APIError is an error response from ListenBrainz, e.g. {"code":404,"error":"Cannot find user"}.
+// It matches the lastfm package's errors with errors.Is, so callers handle both providers alike.
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("listenbrainz api error (http %d): %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case lastfm.ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case lastfm.ErrInvalidAPIKey:
+		return e.StatusCode == http.StatusUnauthorized
+	case lastfm.ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case lastfm.ErrUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// Service reads listening data from ListenBrainz and maps it into the lastfm types
+type Service struct {
+	config     *config.Config
+	baseURL    string
+	httpClient *http.Client
+}
+
+var _ lastfm.Provider = (*Service)(nil)
+
+// NewService creates a client for cfg.ListenBrainzURL using httpClient (a client with a
+// 10 second timeout when nil)
+func NewService(cfg *config.Config, httpClient *http.Client) *Service {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Service{
+		config:     cfg,
+		baseURL:    cfg.ListenBrainzURL,
+		httpClient: httpClient,
+	}
+}
+
+// get fetches path and decodes the JSON response into out. It reports whether there was a
+// body, since statistics that haven't been calculated yet come back as 204 No Content.
+func (s *Service) get(ctx context.Context, path string, params url.Values, out any) (bool, error) {
+	apiURL := s.baseURL + path
+	if len(params) > 0 {
+		apiURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return false, err
+	}
+	if s.config.ListenBrainzToken != "" {
+		req.Header.Set("Authorization", "Token "+s.config.ListenBrainzToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch from ListenBrainz: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return false, apiErr
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return true, nil
+}
+
+func (s *Service) userPath(suffix string) string {
+	return "/1/user/" + url.PathEscape(s.config.ListenBrainzUsername) + suffix
+}
+
+func (s *Service) statsPath(suffix string) string {
+	return "/1/stats/user/" + url.PathEscape(s.config.ListenBrainzUsername) + suffix
+}
+
+type trackMetadata struct {
+	ArtistName  string `json:"artist_name"`
+	TrackName   string `json:"track_name"`
+	ReleaseName string `json:"release_name"`
+	MBIDMapping *struct {
+		RecordingMBID  string `json:"recording_mbid"`
+		ReleaseMBID    string `json:"release_mbid"`
+		CAAReleaseMBID string `json:"caa_release_mbid"`
+	} `json:"mbid_mapping"`
+}
+
+type listen struct {
+	ListenedAt    int64         `json:"listened_at"`
+	PlayingNow    bool          `json:"playing_now"`
+	TrackMetadata trackMetadata `json:"track_metadata"`
+}
+
+type listensResponse struct {
+	Payload struct {
+		Count          int      `json:"count"`
+		Listens        []listen `json:"listens"`
+		OldestListenTS int64    `json:"oldest_listen_ts"`
+	} `json:"payload"`
+}
+
+// recordingURL links to the MusicBrainz recording when the listen was matched to one
+func (m trackMetadata) recordingURL() string {
+	if m.MBIDMapping == nil || m.MBIDMapping.RecordingMBID == "" {
+		return ""
+	}
+	return "https://musicbrainz.org/recording/" + m.MBIDMapping.RecordingMBID
+}
+
+func (m trackMetadata) albumArt() string {
+	if m.MBIDMapping == nil {
+		return ""
+	}
+	return coverArt(m.MBIDMapping.CAAReleaseMBID)
+}
+
+// coverArt links to the Cover Art Archive front image of a release, at the size Last.fm's
+// extralarge images have
+func coverArt(releaseMBID string) string {
+	if releaseMBID == "" {
+		return ""
+	}
+	return "https://coverartarchive.org/release/" + releaseMBID + "/front-250"
+}
+
+func formatTS(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).Format(time.RFC3339)
+}
+
+func (s *Service) listens(ctx context.Context, count int) (*listensResponse, error) {
+	var resp listensResponse
+	params := url.Values{"count": {strconv.Itoa(count)}}
+	if _, err := s.get(ctx, s.userPath("/listens"), params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetCurrentlyPlaying returns the playing-now listen, or the latest listen when nothing is
+// playing, like Last.fm does
+func (s *Service) GetCurrentlyPlaying(ctx context.Context) (*lastfm.NowPlayingInfo, error) {
+	info := &lastfm.NowPlayingInfo{
+		IsPlaying:   false,
+		LastUpdated: time.Now().Format(time.RFC3339),
+	}
+
+	var playing listensResponse
+	if _, err := s.get(ctx, s.userPath("/playing-now"), nil, &playing); err != nil {
+		return nil, err
+	}
+
+	var track listen
+	if len(playing.Payload.Listens) > 0 {
+		track = playing.Payload.Listens[0]
+		info.IsPlaying = true
+	} else {
+		recent, err := s.listens(ctx, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(recent.Payload.Listens) == 0 {
+			return info, nil
+		}
+		track = recent.Payload.Listens[0]
+		info.PlayedAt = formatTS(track.ListenedAt)
+	}
+
+	info.SongName = track.TrackMetadata.TrackName
+	info.ArtistName = track.TrackMetadata.ArtistName
+	info.AlbumName = track.TrackMetadata.ReleaseName
+	info.AlbumArt = track.TrackMetadata.albumArt()
+	info.SongURL = track.TrackMetadata.recordingURL()
+
+	return info, nil
+}
+
+func (s *Service) GetRecentTracks(ctx context.Context, limit int) (*lastfm.RecentTracksInfo, error) {
+	resp, err := s.listens(ctx, clampLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &lastfm.RecentTracksInfo{
+		Tracks: make([]lastfm.RecentTrack, 0, len(resp.Payload.Listens)),
+	}
+	for _, l := range resp.Payload.Listens {
+		info.Tracks = append(info.Tracks, lastfm.RecentTrack{
+			Name:     l.TrackMetadata.TrackName,
+			Artist:   l.TrackMetadata.ArtistName,
+			Album:    l.TrackMetadata.ReleaseName,
+			AlbumArt: l.TrackMetadata.albumArt(),
+			URL:      l.TrackMetadata.recordingURL(),
+			PlayedAt: formatTS(l.ListenedAt),
+		})
+	}
+
+	return info, nil
+}
+
+type topArtistsResponse struct {
+	Payload struct {
+		Artists []struct {
+			ArtistName  string `json:"artist_name"`
+			ListenCount int    `json:"listen_count"`
+		} `json:"artists"`
+	} `json:"payload"`
+}
+
+func (s *Service) GetTopArtists(ctx context.Context, limit int, period string) (*lastfm.TopArtistsInfo, error) {
+	var resp topArtistsResponse
+	if _, err := s.get(ctx, s.statsPath("/artists"), statsParams(limit, period), &resp); err != nil {
+		return nil, err
+	}
+
+	info := &lastfm.TopArtistsInfo{
+		Artists: make([]lastfm.TopArtist, 0, len(resp.Payload.Artists)),
+	}
+	for _, artist := range resp.Payload.Artists {
+		info.Artists = append(info.Artists, lastfm.TopArtist{
+			Name:      artist.ArtistName,
+			PlayCount: strconv.Itoa(artist.ListenCount),
+		})
+	}
+
+	return info, nil
+}
+
+type topRecordingsResponse struct {
+	Payload struct {
+		Recordings []struct {
+			TrackName      string `json:"track_name"`
+			ArtistName     string `json:"artist_name"`
+			ListenCount    int    `json:"listen_count"`
+			RecordingMBID  string `json:"recording_mbid"`
+			CAAReleaseMBID string `json:"caa_release_mbid"`
+		} `json:"recordings"`
+	} `json:"payload"`
+}
+
+func (s *Service) GetTopTracks(ctx context.Context, limit int, period string) (*lastfm.TopTracksInfo, error) {
+	var resp topRecordingsResponse
+	if _, err := s.get(ctx, s.statsPath("/recordings"), statsParams(limit, period), &resp); err != nil {
+		return nil, err
+	}
+
+	info := &lastfm.TopTracksInfo{
+		Tracks: make([]lastfm.TopTrack, 0, len(resp.Payload.Recordings)),
+	}
+	for _, recording := range resp.Payload.Recordings {
+		trackURL := ""
+		if recording.RecordingMBID != "" {
+			trackURL = "https://musicbrainz.org/recording/" + recording.RecordingMBID
+		}
+		info.Tracks = append(info.Tracks, lastfm.TopTrack{
+			Name:      recording.TrackName,
+			Artist:    recording.ArtistName,
+			PlayCount: strconv.Itoa(recording.ListenCount),
+			AlbumArt:  coverArt(recording.CAAReleaseMBID),
+			URL:       trackURL,
+		})
+	}
+
+	return info, nil
+}
+
+type topReleasesResponse struct {
+	Payload struct {
+		Releases []struct {
+			ReleaseName    string `json:"release_name"`
+			ArtistName     string `json:"artist_name"`
+			ListenCount    int    `json:"listen_count"`
+			ReleaseMBID    string `json:"release_mbid"`
+			CAAReleaseMBID string `json:"caa_release_mbid"`
+		} `json:"releases"`
+	} `json:"payload"`
+}
+
+func (s *Service) GetTopAlbums(ctx context.Context, limit int, period string) (*lastfm.TopAlbumsInfo, error) {
+	var resp topReleasesResponse
+	if _, err := s.get(ctx, s.statsPath("/releases"), statsParams(limit, period), &resp); err != nil {
+		return nil, err
+	}
+
+	info := &lastfm.TopAlbumsInfo{
+		Albums: make([]lastfm.TopAlbum, 0, len(resp.Payload.Releases)),
+	}
+	for _, release := range resp.Payload.Releases {
+		albumURL := ""
+		if release.ReleaseMBID != "" {
+			albumURL = "https://musicbrainz.org/release/" + release.ReleaseMBID
+		}
+		info.Albums = append(info.Albums, lastfm.TopAlbum{
+			Name:      release.ReleaseName,
+			Artist:    release.ArtistName,
+			PlayCount: strconv.Itoa(release.ListenCount),
+			AlbumArt:  coverArt(release.CAAReleaseMBID),
+			URL:       albumURL,
+		})
+	}
+
+	return info, nil
+}
+
+type listenCountResponse struct {
+	Payload struct {
+		Count int `json:"count"`
+	} `json:"payload"`
+}
+
+// GetListeningStats reports the listen count, and account age measured from the oldest
+// listen since ListenBrainz doesn't publish when an account was created
+func (s *Service) GetListeningStats(ctx context.Context) (*lastfm.ListeningStats, error) {
+	var count listenCountResponse
+	if _, err := s.get(ctx, s.userPath("/listen-count"), nil, &count); err != nil {
+		return nil, err
+	}
+
+	recent, err := s.listens(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var accountAge *lastfm.AccountAge
+	if oldest := recent.Payload.OldestListenTS; oldest > 0 {
+		accountAge = lastfm.NewAccountAge(time.Unix(oldest, 0), time.Now())
+	}
+
+	return &lastfm.ListeningStats{
+		TotalScrobbles: strconv.Itoa(count.Payload.Count),
+		AccountAge:     accountAge,
+		Username:       s.config.ListenBrainzUsername,
+	}, nil
+}
+
+// ranges maps Last.fm periods, and their aliases, to ListenBrainz statistics ranges
+var ranges = map[string]string{
+	"weekly":  "week",
+	"7day":    "week",
+	"monthly": "month",
+	"1month":  "month",
+	"3month":  "quarter",
+	"6month":  "half_yearly",
+	"yearly":  "year",
+	"12month": "year",
+	"alltime": "all_time",
+	"overall": "all_time",
+}
+
+// statsParams mirrors the Last.fm service: limit defaults to 10 and is capped at 50, unknown
+// periods fall back to the last week
+func statsParams(limit int, period string) url.Values {
+	statsRange, ok := ranges[period]
+	if !ok {
+		statsRange = "week"
+	}
+	return url.Values{
+		"count": {strconv.Itoa(clampLimit(limit))},
+		"range": {statsRange},
+	}
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	if limit > 50 {
+		return 50
+	}
+	return limit
+}
@@ -0,0 +1,222 @@
+package listenbrainz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+)
+
+// newFakeServer serves canned responses by path and records the requests it received
+func newFakeServer(t *testing.T, routes map[string]func(w http.ResponseWriter, r *http.Request)) (*Service, *[]*http.Request) {
+	t.Helper()
+
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		handler, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"error":"Cannot find user: nobody"}`))
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		ListenBrainzUsername: "tringl",
+		ListenBrainzToken:    "secret",
+		ListenBrainzURL:      server.URL,
+	}
+	return NewService(cfg, server.Client()), &requests
+}
+
+func respond(body string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+const listensBody = `{"payload":{"count":1,"oldest_listen_ts":1500000000,"listens":[{
+	"listened_at":1700000000,
+	"track_metadata":{"artist_name":"Boards of Canada","track_name":"Roygbiv","release_name":"Music Has the Right to Children",
+		"mbid_mapping":{"recording_mbid":"rec-1","release_mbid":"rel-1","caa_release_mbid":"caa-1"}}}]}}`
+
+func TestGetCurrentlyPlaying(t *testing.T) {
+	service, requests := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+		"/1/user/tringl/playing-now": respond(`{"payload":{"count":1,"playing_now":true,"listens":[{
+			"playing_now":true,
+			"track_metadata":{"artist_name":"Aphex Twin","track_name":"Xtal","release_name":"Selected Ambient Works 85-92"}}]}}`),
+	})
+
+	info, err := service.GetCurrentlyPlaying(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentlyPlaying: %v", err)
+	}
+	if !info.IsPlaying || info.SongName != "Xtal" || info.ArtistName != "Aphex Twin" || info.PlayedAt != "" {
+		t.Errorf("unexpected now playing info: %+v", info)
+	}
+	if got := (*requests)[0].Header.Get("Authorization"); got != "Token secret" {
+		t.Errorf("Authorization header = %q, want %q", got, "Token secret")
+	}
+}
+
+func TestGetCurrentlyPlayingFallsBackToLastListen(t *testing.T) {
+	service, requests := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+		"/1/user/tringl/playing-now": respond(`{"payload":{"count":0,"playing_now":true,"listens":[]}}`),
+		"/1/user/tringl/listens":     respond(listensBody),
+	})
+
+	info, err := service.GetCurrentlyPlaying(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentlyPlaying: %v", err)
+	}
+	if info.IsPlaying {
+		t.Error("expected IsPlaying to be false")
+	}
+	if info.SongName != "Roygbiv" || info.PlayedAt == "" {
+		t.Errorf("unexpected now playing info: %+v", info)
+	}
+	if info.AlbumArt != "https://coverartarchive.org/release/caa-1/front-250" {
+		t.Errorf("AlbumArt = %q", info.AlbumArt)
+	}
+	if info.SongURL != "https://musicbrainz.org/recording/rec-1" {
+		t.Errorf("SongURL = %q", info.SongURL)
+	}
+	if got := (*requests)[1].URL.Query().Get("count"); got != "1" {
+		t.Errorf("count = %q, want 1", got)
+	}
+}
+
+func TestGetRecentTracks(t *testing.T) {
+	service, requests := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+		"/1/user/tringl/listens": respond(listensBody),
+	})
+
+	info, err := service.GetRecentTracks(context.Background(), 500)
+	if err != nil {
+		t.Fatalf("GetRecentTracks: %v", err)
+	}
+	if len(info.Tracks) != 1 || info.Tracks[0].Album != "Music Has the Right to Children" {
+		t.Errorf("unexpected tracks: %+v", info.Tracks)
+	}
+	if got := (*requests)[0].URL.Query().Get("count"); got != "50" {
+		t.Errorf("count = %q, want limit capped at 50", got)
+	}
+}
+
+func TestGetTopStats(t *testing.T) {
+	service, requests := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+		"/1/stats/user/tringl/artists": respond(`{"payload":{"artists":[
+			{"artist_name":"Burial","listen_count":42},{"artist_name":"Four Tet","listen_count":17}]}}`),
+		"/1/stats/user/tringl/recordings": respond(`{"payload":{"recordings":[
+			{"track_name":"Archangel","artist_name":"Burial","listen_count":9,"recording_mbid":"rec-2","caa_release_mbid":"caa-2"}]}}`),
+		"/1/stats/user/tringl/releases": respond(`{"payload":{"releases":[
+			{"release_name":"Untrue","artist_name":"Burial","listen_count":30,"release_mbid":"rel-3"}]}}`),
+	})
+	ctx := context.Background()
+
+	artists, err := service.GetTopArtists(ctx, 5, "3month")
+	if err != nil {
+		t.Fatalf("GetTopArtists: %v", err)
+	}
+	if len(artists.Artists) != 2 || artists.Artists[0].Name != "Burial" || artists.Artists[0].PlayCount != "42" {
+		t.Errorf("unexpected artists: %+v", artists.Artists)
+	}
+
+	tracks, err := service.GetTopTracks(ctx, 5, "overall")
+	if err != nil {
+		t.Fatalf("GetTopTracks: %v", err)
+	}
+	if len(tracks.Tracks) != 1 || tracks.Tracks[0].URL != "https://musicbrainz.org/recording/rec-2" {
+		t.Errorf("unexpected tracks: %+v", tracks.Tracks)
+	}
+
+	albums, err := service.GetTopAlbums(ctx, 5, "bogus")
+	if err != nil {
+		t.Fatalf("GetTopAlbums: %v", err)
+	}
+	if len(albums.Albums) != 1 || albums.Albums[0].URL != "https://musicbrainz.org/release/rel-3" || albums.Albums[0].AlbumArt != "" {
+		t.Errorf("unexpected albums: %+v", albums.Albums)
+	}
+
+	wantRanges := []string{"quarter", "all_time", "week"}
+	for i, want := range wantRanges {
+		if got := (*requests)[i].URL.Query().Get("range"); got != want {
+			t.Errorf("request %d range = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestStatsNotCalculatedYet(t *testing.T) {
+	service, _ := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+		"/1/stats/user/tringl/artists": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	info, err := service.GetTopArtists(context.Background(), 10, "7day")
+	if err != nil {
+		t.Fatalf("GetTopArtists: %v", err)
+	}
+	if info.Artists == nil || len(info.Artists) != 0 {
+		t.Errorf("expected an empty, non-nil list, got %#v", info.Artists)
+	}
+}
+
+func TestGetListeningStats(t *testing.T) {
+	service, _ := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+		"/1/user/tringl/listen-count": respond(`{"payload":{"count":12345}}`),
+		"/1/user/tringl/listens":      respond(listensBody),
+	})
+
+	stats, err := service.GetListeningStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetListeningStats: %v", err)
+	}
+	if stats.TotalScrobbles != "12345" || stats.Username != "tringl" || stats.AccountAge == nil {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if !stats.AccountAge.RegisteredAt.Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("RegisteredAt = %v, want the oldest listen", stats.AccountAge.RegisteredAt)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, lastfm.ErrNotFound},
+		{http.StatusUnauthorized, lastfm.ErrInvalidAPIKey},
+		{http.StatusTooManyRequests, lastfm.ErrRateLimited},
+		{http.StatusBadGateway, lastfm.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			service, _ := newFakeServer(t, map[string]func(http.ResponseWriter, *http.Request){
+				"/1/user/tringl/listens": func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(`{"code":0,"error":"nope"}`))
+				},
+			})
+
+			_, err := service.GetRecentTracks(context.Background(), 10)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want errors.Is %v", err, tt.want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != "nope" {
+				t.Errorf("unexpected APIError: %+v", apiErr)
+			}
+		})
+	}
+}
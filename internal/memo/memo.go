@@ -0,0 +1,73 @@
+// Package memo keeps the results of slow computations in memory for a while
+package memo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache remembers one computed value per key for its TTL. Concurrent misses for a key share
+// a single computation, and the last good value is returned if recomputing it fails.
+// Keys are never evicted, so they must come from a small, fixed set.
+type Cache[K comparable, V any] struct {
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu      sync.Mutex
+	results map[K]result[V]
+	group   singleflight.Group
+}
+
+type result[V any] struct {
+	value      V
+	computedAt time.Time
+}
+
+// New returns a cache whose values stay fresh for ttl and whose computations are cancelled
+// after timeout
+func New[K comparable, V any](ttl, timeout time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:     ttl,
+		timeout: timeout,
+		results: make(map[K]result[V]),
+	}
+}
+
+// Get returns the value for key, calling compute when there is no fresh one. compute gets a
+// context detached from ctx: callers waiting on the same key may give up, but the result is
+// still worth keeping for the next request.
+func (c *Cache[K, V]) Get(ctx context.Context, key K, compute func(context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+	cached, ok := c.results[key]
+	c.mu.Unlock()
+	if ok && time.Since(cached.computedAt) < c.ttl {
+		return cached.value, nil
+	}
+
+	value, err, _ := c.group.Do(fmt.Sprint(key), func() (any, error) {
+		computeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancel()
+
+		value, err := compute(computeCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.results[key] = result[V]{value: value, computedAt: time.Now()}
+		c.mu.Unlock()
+		return value, nil
+	})
+	if err != nil {
+		if ok {
+			return cached.value, nil
+		}
+		var zero V
+		return zero, err
+	}
+	return value.(V), nil
+}
@@ -0,0 +1,70 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetSharesComputations(t *testing.T) {
+	cache := New[string, int](time.Hour, time.Second)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	compute := func(context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := cache.Get(context.Background(), "a", compute); err != nil || v != 42 {
+				t.Errorf("Get = %d, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Fresh values are served without computing again
+	if v, _ := cache.Get(context.Background(), "a", compute); v != 42 || calls.Load() != 1 {
+		t.Errorf("got %d after %d computations, want 42 after 1", v, calls.Load())
+	}
+}
+
+func TestGetKeepsLastGoodValue(t *testing.T) {
+	cache := New[int, string](0, time.Second)
+	failure := errors.New("upstream down")
+
+	if _, err := cache.Get(context.Background(), 1, func(context.Context) (string, error) { return "", failure }); !errors.Is(err, failure) {
+		t.Fatalf("error without a cached value = %v, want %v", err, failure)
+	}
+
+	cache.Get(context.Background(), 1, func(context.Context) (string, error) { return "good", nil })
+	// A zero TTL makes every Get recompute, so this exercises the fallback
+	v, err := cache.Get(context.Background(), 1, func(context.Context) (string, error) { return "", failure })
+	if err != nil || v != "good" {
+		t.Errorf("Get = %q, %v; want the last good value", v, err)
+	}
+}
+
+func TestGetOutlivesCaller(t *testing.T) {
+	cache := New[string, bool](time.Hour, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	v, err := cache.Get(ctx, "a", func(ctx context.Context) (bool, error) {
+		return ctx.Err() == nil, nil
+	})
+	if err != nil || !v {
+		t.Errorf("computation saw the caller's cancellation: %v, %v", v, err)
+	}
+}
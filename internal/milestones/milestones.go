@@ -0,0 +1,296 @@
+package milestones
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/contact"
+	"tringldev-server/internal/lastfm"
+)
+
+const (
+	// Size of the weekly chart an artist has to enter to be announced
+	topArtistPositions = 3
+
+	// Most played tracks checked against the play milestones, one page of user.gettoptracks
+	trackPlaysChecked = 1000
+
+	// Embed colours for the three kinds of notification
+	colorScrobbles = 15844367 // Gold
+	colorTopArtist = 5814783  // Blue
+	colorTrack     = 10181046 // Purple
+)
+
+// Watcher posts Discord notifications when total scrobbles cross a milestone, an artist enters
+// the weekly top 3 for the first time or a track reaches a play milestone. What has been
+// announced is kept in SQLite so restarts don't repeat anything.
+type Watcher struct {
+	config  *config.Config
+	db      *sql.DB
+	lastfm  *lastfm.Service
+	contact *contact.Service
+}
+
+func NewWatcher(cfg *config.Config, db *sql.DB, service *lastfm.Service, contactService *contact.Service) (*Watcher, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS milestones (
+		key TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create milestones table: %w", err)
+	}
+
+	return &Watcher{
+		config:  cfg,
+		db:      db,
+		lastfm:  service,
+		contact: contactService,
+	}, nil
+}
+
+// Enabled reports whether the watcher has a check interval and somewhere to post
+func (w *Watcher) Enabled() bool {
+	return w.config.MilestoneCheckInterval > 0 && w.config.NotifyWebhook != "" && w.config.LastFMUsername != ""
+}
+
+func (w *Watcher) Start() {
+	if !w.Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.config.MilestoneCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.Check(context.Background()); err != nil {
+				log.Printf("Milestone check failed: %v\n", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// notification is an announcement together with the key that marks it as sent
+type notification struct {
+	key   string
+	value int64
+	embed contact.DiscordEmbed
+}
+
+// Check looks for new milestones and announces them. The first check only records the
+// current state, so turning the watcher on doesn't announce years of history at once.
+func (w *Watcher) Check(ctx context.Context) error {
+	seeded, err := w.has("seeded")
+	if err != nil {
+		return err
+	}
+
+	var pending []notification
+	var errs []error
+
+	scrobbles, err := w.checkScrobbles(ctx)
+	pending = append(pending, scrobbles...)
+	errs = append(errs, err)
+
+	artists, err := w.checkTopArtists(ctx)
+	pending = append(pending, artists...)
+	errs = append(errs, err)
+
+	tracks, err := w.checkTrackPlays(ctx)
+	pending = append(pending, tracks...)
+	errs = append(errs, err)
+
+	for _, n := range pending {
+		if seeded && n.embed.Title != "" {
+			if err := w.contact.Notify(n.embed); err != nil {
+				// Left unmarked so the next check tries again
+				errs = append(errs, fmt.Errorf("failed to announce %s: %w", n.key, err))
+				continue
+			}
+		}
+		if err := w.mark(n.key, n.value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if !seeded {
+		return w.mark("seeded", time.Now().Unix())
+	}
+	return nil
+}
+
+// checkScrobbles announces the highest milestone crossed since the last check
+func (w *Watcher) checkScrobbles(ctx context.Context) ([]notification, error) {
+	stats, err := w.lastfm.GetListeningStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	total, err := strconv.Atoi(stats.TotalScrobbles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrobble count %q", stats.TotalScrobbles)
+	}
+
+	previous, err := w.value("scrobbles")
+	if err != nil {
+		return nil, err
+	}
+	if int64(total) <= previous {
+		return nil, nil
+	}
+
+	n := notification{key: "scrobbles", value: int64(total)}
+	if crossed := crossedMilestone(w.config.ScrobbleMilestones, int(previous), total); crossed > 0 {
+		n.embed = contact.DiscordEmbed{
+			Title:       fmt.Sprintf("%s scrobbles!", formatCount(crossed)),
+			Description: fmt.Sprintf("%s just passed %s scrobbles on Last.fm.", stats.Username, formatCount(crossed)),
+			Color:       colorScrobbles,
+			URL:         "https://www.last.fm/user/" + stats.Username,
+			Fields: []contact.DiscordEmbedField{
+				{Name: "Total", Value: formatCount(total), Inline: true},
+			},
+		}
+	}
+	return []notification{n}, nil
+}
+
+// checkTopArtists announces artists in this week's top 3 that have never been there before
+func (w *Watcher) checkTopArtists(ctx context.Context) ([]notification, error) {
+	top, err := w.lastfm.GetTopArtists(ctx, topArtistPositions, "7day")
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []notification
+	for i, artist := range top.Artists {
+		key := "top3:" + strings.ToLower(artist.Name)
+		seen, err := w.has(key)
+		if err != nil {
+			return nil, err
+		}
+		if seen {
+			continue
+		}
+
+		pending = append(pending, notification{
+			key:   key,
+			value: time.Now().Unix(),
+			embed: contact.DiscordEmbed{
+				Title:       fmt.Sprintf("New in the top %d: %s", topArtistPositions, artist.Name),
+				Description: fmt.Sprintf("%s is #%d this week, a first for the weekly top %d.", artist.Name, i+1, topArtistPositions),
+				Color:       colorTopArtist,
+				Fields: []contact.DiscordEmbedField{
+					{Name: "Plays this week", Value: artist.PlayCount, Inline: true},
+				},
+			},
+		})
+	}
+	return pending, nil
+}
+
+// checkTrackPlays announces tracks reaching a play milestone, only the highest one reached
+// for each track
+func (w *Watcher) checkTrackPlays(ctx context.Context) ([]notification, error) {
+	if len(w.config.TrackPlayMilestones) == 0 {
+		return nil, nil
+	}
+
+	tracks, err := w.lastfm.GetTopTrackPlays(ctx, "overall", trackPlaysChecked)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []notification
+	for _, track := range tracks {
+		trackKey := "plays:" + strings.ToLower(track.Artist) + "\x1f" + strings.ToLower(track.Name)
+		previous, err := w.value(trackKey)
+		if err != nil {
+			return nil, err
+		}
+
+		crossed := crossedMilestone(w.config.TrackPlayMilestones, int(previous), track.PlayCount)
+		if crossed == 0 {
+			continue
+		}
+
+		pending = append(pending, notification{
+			key:   trackKey,
+			value: int64(crossed),
+			embed: contact.DiscordEmbed{
+				Title:       fmt.Sprintf("%s plays of %s", formatCount(crossed), track.Name),
+				Description: fmt.Sprintf("%s by %s has now been played %s times.", track.Name, track.Artist, formatCount(track.PlayCount)),
+				Color:       colorTrack,
+				URL:         track.URL,
+			},
+		})
+	}
+	return pending, nil
+}
+
+// crossedMilestone returns the highest milestone in (previous, current], or 0
+func crossedMilestone(milestones []int, previous, current int) int {
+	crossed := 0
+	for _, m := range milestones {
+		if m > previous && m <= current && m > crossed {
+			crossed = m
+		}
+	}
+	return crossed
+}
+
+// formatCount writes n with thousands separators, e.g. 25,000
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+func (w *Watcher) has(key string) (bool, error) {
+	_, found, err := w.lookup(key)
+	return found, err
+}
+
+// value returns what is stored under key, 0 when nothing is
+func (w *Watcher) value(key string) (int64, error) {
+	value, _, err := w.lookup(key)
+	return value, err
+}
+
+func (w *Watcher) lookup(key string) (int64, bool, error) {
+	var value int64
+	err := w.db.QueryRow("SELECT value FROM milestones WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read milestones: %w", err)
+	}
+	return value, true, nil
+}
+
+func (w *Watcher) mark(key string, value int64) error {
+	_, err := w.db.Exec(`
+		INSERT INTO milestones (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to store milestone %s: %w", key, err)
+	}
+	return nil
+}
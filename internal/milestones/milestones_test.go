@@ -0,0 +1,243 @@
+package milestones
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/contact"
+	"tringldev-server/internal/lastfm"
+
+	_ "modernc.org/sqlite"
+)
+
+type track struct {
+	artist, name string
+	plays        int
+}
+
+// fakeStats answers the Last.fm calls a check makes from a profile the test can change
+type fakeStats struct {
+	mu         sync.Mutex
+	scrobbles  int
+	topArtists []string
+	tracks     []track
+}
+
+func (f *fakeStats) set(change func(f *fakeStats)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	change(f)
+}
+
+func (f *fakeStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Query().Get("method") {
+	case "user.getinfo":
+		json.NewEncoder(w).Encode(map[string]any{"user": map[string]any{
+			"name":      "tringl",
+			"playcount": strconv.Itoa(f.scrobbles),
+		}})
+	case "user.gettopartists":
+		artists := make([]map[string]string, 0, len(f.topArtists))
+		for i, name := range f.topArtists {
+			artists = append(artists, map[string]string{"name": name, "playcount": strconv.Itoa(50 - i)})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"topartists": map[string]any{"artist": artists}})
+	case "user.gettoptracks":
+		tracks := make([]map[string]any, 0, len(f.tracks))
+		for _, t := range f.tracks {
+			tracks = append(tracks, map[string]any{
+				"name":      t.name,
+				"playcount": strconv.Itoa(t.plays),
+				"artist":    map[string]string{"name": t.artist},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"toptracks": map[string]any{"track": tracks}})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": 3, "message": "Invalid method"})
+	}
+}
+
+// fakeWebhook records the embed titles it accepts and fails with status when it is set
+type fakeWebhook struct {
+	mu       sync.Mutex
+	status   int
+	attempts int
+	titles   []string
+}
+
+func (f *fakeWebhook) failWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+// announced returns the titles accepted so far and forgets them
+func (f *fakeWebhook) announced() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	titles := f.titles
+	f.titles = nil
+	return titles
+}
+
+func (f *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempts++
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	var webhook contact.DiscordWebhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, embed := range webhook.Embeds {
+		f.titles = append(f.titles, embed.Title)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newTestWatcher points a Watcher with an in-memory database at stats and webhook
+func newTestWatcher(t *testing.T, stats *fakeStats, webhook *fakeWebhook) *Watcher {
+	t.Helper()
+
+	lastfmServer := httptest.NewServer(stats)
+	t.Cleanup(lastfmServer.Close)
+	webhookServer := httptest.NewServer(webhook)
+	t.Cleanup(webhookServer.Close)
+
+	cfg := &config.Config{
+		LastFMUsername:      "tringl",
+		LastFMAPIKey:        "test-key",
+		NotifyWebhook:       webhookServer.URL,
+		ScrobbleMilestones:  []int{1000, 5000, 10000},
+		TrackPlayMilestones: []int{100, 250},
+	}
+	client := lastfm.NewClient(lastfmServer.URL+"/2.0/", cfg.LastFMAPIKey, lastfmServer.Client())
+	client.MaxRetries = 0
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	w, err := NewWatcher(cfg, db, lastfm.NewServiceWithClient(cfg, client), contact.NewService(cfg))
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	return w
+}
+
+func mustCheck(t *testing.T, w *Watcher) {
+	t.Helper()
+	if err := w.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+}
+
+func TestCheckAnnouncesOnce(t *testing.T) {
+	stats := &fakeStats{
+		scrobbles:  4990,
+		topArtists: []string{"Burial", "Four Tet", "Loraine James"},
+		tracks:     []track{{"Burial", "Archangel", 99}, {"Four Tet", "Baby", 260}},
+	}
+	webhook := &fakeWebhook{}
+	w := newTestWatcher(t, stats, webhook)
+
+	// The first check records what is already there without announcing it
+	mustCheck(t, w)
+	if titles := webhook.announced(); len(titles) != 0 {
+		t.Fatalf("seeding announced %q", titles)
+	}
+	mustCheck(t, w)
+	if titles := webhook.announced(); len(titles) != 0 {
+		t.Fatalf("unchanged check announced %q", titles)
+	}
+
+	stats.set(func(f *fakeStats) {
+		f.scrobbles = 5003
+		// Last.fm changing an artist's capitalisation doesn't make them new
+		f.topArtists = []string{"BURIAL", "Kelly Lee Owens", "four tet"}
+		f.tracks = []track{{"Burial", "Archangel", 101}, {"Four Tet", "Baby", 261}}
+	})
+	mustCheck(t, w)
+	want := []string{"5,000 scrobbles!", "New in the top 3: Kelly Lee Owens", "100 plays of Archangel"}
+	if titles := webhook.announced(); !reflect.DeepEqual(titles, want) {
+		t.Errorf("announced %q, want %q", titles, want)
+	}
+
+	mustCheck(t, w)
+	if titles := webhook.announced(); len(titles) != 0 {
+		t.Errorf("announced %q again", titles)
+	}
+}
+
+func TestCheckRetriesFailedAnnouncements(t *testing.T) {
+	stats := &fakeStats{scrobbles: 900, topArtists: []string{"Burial"}}
+	webhook := &fakeWebhook{}
+	w := newTestWatcher(t, stats, webhook)
+	mustCheck(t, w)
+
+	stats.set(func(f *fakeStats) { f.scrobbles = 1001 })
+	webhook.failWith(http.StatusInternalServerError)
+	if err := w.Check(context.Background()); err == nil {
+		t.Fatal("Check succeeded although the webhook failed")
+	}
+	webhook.mu.Lock()
+	attempts := webhook.attempts
+	webhook.mu.Unlock()
+	if attempts != 1 {
+		t.Fatalf("webhook called %d times, want 1", attempts)
+	}
+
+	// Nothing was marked, so the next check announces the milestone
+	webhook.failWith(0)
+	mustCheck(t, w)
+	if titles := webhook.announced(); !reflect.DeepEqual(titles, []string{"1,000 scrobbles!"}) {
+		t.Errorf("retry announced %q", titles)
+	}
+	mustCheck(t, w)
+	if titles := webhook.announced(); len(titles) != 0 {
+		t.Errorf("announced %q after a successful retry", titles)
+	}
+}
+
+func TestCrossedMilestone(t *testing.T) {
+	milestones := []int{10000, 1000, 5000}
+	tests := []struct {
+		previous, current int
+		want              int
+	}{
+		{900, 999, 0},
+		{900, 1000, 1000},
+		{1000, 4999, 0},
+		// Several at once announce only the highest
+		{900, 12000, 10000},
+		{4000, 10000, 10000},
+		{10000, 20000, 0},
+	}
+	for _, tt := range tests {
+		if got := crossedMilestone(milestones, tt.previous, tt.current); got != tt.want {
+			t.Errorf("crossedMilestone(%d, %d) = %d, want %d", tt.previous, tt.current, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package nowplaying
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+)
+
+const (
+	// Sent when nothing else has been for this long, so proxies keep idle streams open and
+	// clients notice dead connections
+	heartbeatInterval = 25 * time.Second
+
+	// Events buffered per subscriber before a slow client starts missing intermediate ones
+	subscriberBuffer = 4
+)
+
+var ErrTooManySubscribers = errors.New("too many now-playing subscribers")
+
+// Source is where the hub reads the current track from, normally the cached Last.fm service
+type Source interface {
+	GetCurrentlyPlaying(ctx context.Context) (*lastfm.NowPlayingInfo, error)
+}
+
+// Event is a change of the currently playing track. IDs are unix milliseconds of when the
+// change was seen, so they keep increasing across restarts and work as SSE event IDs.
+type Event struct {
+	ID         int64                  `json:"id"`
+	NowPlaying *lastfm.NowPlayingInfo `json:"data"`
+}
+
+// Hub polls the source once on behalf of every connected client and fans out changes
+type Hub struct {
+	config *config.Config
+	source Source
+
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	current     *Event
+	wake        chan struct{}
+}
+
+func NewHub(cfg *config.Config, source Source) *Hub {
+	return &Hub{
+		config:      cfg,
+		source:      source,
+		subscribers: make(map[chan Event]struct{}),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Start polls every NowPlayingPollInterval while there is at least one subscriber
+func (h *Hub) Start() {
+	go func() {
+		for {
+			if h.subscriberCount() == 0 {
+				<-h.wake
+				continue
+			}
+
+			h.poll()
+
+			select {
+			case <-h.wake:
+			case <-time.After(h.config.NowPlayingPollInterval):
+			}
+		}
+	}()
+}
+
+func (h *Hub) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	info, err := h.source.GetCurrentlyPlaying(ctx)
+	if err != nil {
+		// Keep the last known state, clients are told again once polling recovers
+		log.Printf("Now playing poll failed: %v\n", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current != nil && sameTrack(h.current.NowPlaying, info) {
+		return
+	}
+
+	id := time.Now().UnixMilli()
+	if h.current != nil && id <= h.current.ID {
+		id = h.current.ID + 1
+	}
+	h.current = &Event{ID: id, NowPlaying: info}
+
+	for ch := range h.subscribers {
+		send(ch, *h.current)
+	}
+}
+
+// sameTrack ignores LastUpdated, which changes on every fetch
+func sameTrack(a, b *lastfm.NowPlayingInfo) bool {
+	return a.IsPlaying == b.IsPlaying &&
+		a.SongName == b.SongName &&
+		a.ArtistName == b.ArtistName &&
+		a.AlbumName == b.AlbumName
+}
+
+// send never blocks the hub: a subscriber whose buffer is full loses its oldest event,
+// which is fine since every event carries the complete state
+func send(ch chan Event, event Event) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a client. The current state is delivered straight away unless
+// lastEventID shows the client already has it, which is how reconnects avoid duplicates.
+// The returned function must be called once the client goes away.
+func (h *Hub) Subscribe(lastEventID int64) (<-chan Event, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.subscribers) >= h.config.NowPlayingMaxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	h.subscribers[ch] = struct{}{}
+
+	if h.current != nil && h.current.ID != lastEventID {
+		ch <- *h.current
+	}
+
+	// Start polling right away if the hub was idle
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+		})
+	}
+	return ch, unsubscribe, nil
+}
+
+func (h *Hub) subscriberCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
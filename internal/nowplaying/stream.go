@@ -0,0 +1,158 @@
+package nowplaying
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// ServeSSE streams change events as text/event-stream. Reconnecting EventSource clients send
+// Last-Event-ID; clients that can't set headers may pass ?lastEventId= instead.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe, err := h.Subscribe(lastEventID(r))
+	if err != nil {
+		rejectSubscriber(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event.NowPlaying)
+			if err != nil {
+				log.Printf("Failed to encode now playing event: %v\n", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: now-playing\ndata: %s\n\n", event.ID, data); err != nil {
+				return
+			}
+			heartbeat.Reset(heartbeatInterval)
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// wsMessage is what WebSocket clients receive: {"type":"now-playing","id":...,"data":{...}}
+// for changes and {"type":"heartbeat"} to keep the connection alive
+type wsMessage struct {
+	Type string `json:"type"`
+	*Event
+}
+
+// ServeWebSocket pushes the same events as ServeSSE over a WebSocket. Browsers can't set
+// headers on the upgrade request, so the last seen event is passed as ?lastEventId=.
+func (h *Hub) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe, err := h.Subscribe(lastEventID(r))
+	if err != nil {
+		rejectSubscriber(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	server := websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.streamWebSocket(ws, events)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func (h *Hub) streamWebSocket(ws *websocket.Conn, events <-chan Event) {
+	defer ws.Close()
+
+	// Incoming messages are ignored, reading only tells us when the client disconnects
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var msg wsMessage
+		select {
+		case <-closed:
+			return
+		case event := <-events:
+			msg = wsMessage{Type: "now-playing", Event: &event}
+			heartbeat.Reset(heartbeatInterval)
+		case <-heartbeat.C:
+			msg = wsMessage{Type: "heartbeat"}
+		}
+
+		ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			return
+		}
+	}
+}
+
+// checkOrigin applies ALLOWED_ORIGINS to the upgrade, since CORS doesn't cover WebSockets.
+// Requests without an Origin come from non-browser clients and are let through.
+func (h *Hub) checkOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+func lastEventID(r *http.Request) int64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	id, _ := strconv.ParseInt(value, 10, 64)
+	return id
+}
+
+func rejectSubscriber(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrTooManySubscribers) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Too many subscribers, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+}
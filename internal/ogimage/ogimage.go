@@ -0,0 +1,248 @@
+package ogimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	Width  = 1200
+	Height = 630
+
+	padding    = 80
+	siteName   = "tringl.dev"
+	titleSize  = 64
+	descSize   = 32
+	footerSize = 28
+)
+
+var (
+	background = color.RGBA{R: 0x11, G: 0x11, B: 0x1b, A: 0xff}
+	accent     = color.RGBA{R: 0x58, G: 0xb9, B: 0xff, A: 0xff}
+	foreground = color.RGBA{R: 0xf5, G: 0xf5, B: 0xf5, A: 0xff}
+	muted      = color.RGBA{R: 0x9a, G: 0x9a, B: 0xad, A: 0xff}
+)
+
+type Service struct {
+	config *config.Config
+
+	titleFace  font.Face
+	descFace   font.Face
+	footerFace font.Face
+
+	// Serialises renders so concurrent crawlers don't write the same file twice
+	mu sync.Mutex
+}
+
+func NewService(cfg *config.Config) (*Service, error) {
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bold font: %w", err)
+	}
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse regular font: %w", err)
+	}
+
+	s := &Service{config: cfg}
+
+	if s.titleFace, err = newFace(bold, titleSize); err != nil {
+		return nil, err
+	}
+	if s.descFace, err = newFace(regular, descSize); err != nil {
+		return nil, err
+	}
+	if s.footerFace, err = newFace(bold, footerSize); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	return face, nil
+}
+
+// URL returns the address of the preview image for a post, absolute when PUBLIC_URL is set
+func (s *Service) URL(post *blog.Post) string {
+	return fmt.Sprintf("%s/api/blogs/%d/og.png?v=%s", s.config.PublicURL, post.ID, revision(post)[:12])
+}
+
+// Path renders the preview image for a post if it isn't cached yet and returns its location on disk
+func (s *Service) Path(post *blog.Post) (string, error) {
+	path := filepath.Join(s.config.OGCacheDir, fmt.Sprintf("%d-%s.png", post.ID, revision(post)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	data, err := s.Render(post)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.config.OGCacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create og cache dir: %w", err)
+	}
+
+	// Older revisions of the same post are no longer reachable
+	stale, _ := filepath.Glob(filepath.Join(s.config.OGCacheDir, fmt.Sprintf("%d-*.png", post.ID)))
+	for _, old := range stale {
+		os.Remove(old)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write og image: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to move og image into place: %w", err)
+	}
+
+	return path, nil
+}
+
+// Render draws the 1200x630 preview card for a post and encodes it as PNG
+func (s *Service) Render(post *blog.Post) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 16, Height), &image.Uniform{C: accent}, image.Point{}, draw.Src)
+
+	maxWidth := Width - 2*padding
+	y := padding + titleSize
+
+	titleLines := wrap(s.titleFace, post.Title, maxWidth, 3)
+	for _, line := range titleLines {
+		drawText(img, s.titleFace, foreground, padding, y, line)
+		y += titleSize + 12
+	}
+
+	y += 16
+	for _, line := range wrap(s.descFace, post.Description, maxWidth, 3) {
+		drawText(img, s.descFace, muted, padding, y, line)
+		y += descSize + 10
+	}
+
+	footerY := Height - padding
+	drawText(img, s.footerFace, accent, padding, footerY, siteName)
+
+	if !post.CreatedAt.IsZero() {
+		date := post.CreatedAt.Format("2 January 2006")
+		dateWidth := font.MeasureString(s.footerFace, date).Ceil()
+		drawText(img, s.footerFace, muted, Width-padding-dateWidth, footerY, date)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode og image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// wrap breaks text into at most maxLines lines that fit within width, ellipsising the last one if it overflows
+func wrap(face font.Face, text string, width, maxLines int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var lines []string
+	current := ""
+	for i, word := range words {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			current = candidate
+			continue
+		}
+
+		if current == "" {
+			// A single word wider than the line, cut it down
+			current = truncate(face, word, width)
+			continue
+		}
+
+		if len(lines) == maxLines-1 {
+			rest := strings.Join(words[i:], " ")
+			lines = append(lines, truncate(face, current+" "+rest, width))
+			return lines
+		}
+
+		lines = append(lines, current)
+		current = word
+		if font.MeasureString(face, current).Ceil() > width {
+			current = truncate(face, current, width)
+		}
+	}
+
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+func truncate(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + "…"
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return "…"
+}
+
+// revision identifies the rendered content of a post so edits produce a new image
+func revision(post *blog.Post) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d", post.Title, post.Description, post.CreatedAt.Unix(), post.UpdatedAt.Unix())
+	return hex.EncodeToString(h.Sum(nil))
+}
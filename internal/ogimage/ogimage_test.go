@@ -0,0 +1,107 @@
+package ogimage
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+
+	"golang.org/x/image/font"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	s, err := NewService(&config.Config{OGCacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+func TestRender(t *testing.T) {
+	s := newTestService(t)
+
+	posts := map[string]blog.Post{
+		"short":    {Blog: blog.Blog{Title: "Hello", Description: "A first post", CreatedAt: time.Date(2025, 10, 6, 0, 0, 0, 0, time.UTC)}},
+		"empty":    {},
+		"long":     {Blog: blog.Blog{Title: strings.Repeat("A very long title that keeps going ", 20), Description: strings.Repeat("and on ", 100)}},
+		"one word": {Blog: blog.Blog{Title: strings.Repeat("W", 500), Description: strings.Repeat("ü", 500)}},
+	}
+	for name, post := range posts {
+		t.Run(name, func(t *testing.T) {
+			data, err := s.Render(&post)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			cfg, err := png.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			if cfg.Width != Width || cfg.Height != Height {
+				t.Errorf("image is %dx%d, want %dx%d", cfg.Width, cfg.Height, Width, Height)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	s := newTestService(t)
+	width := Width - 2*padding
+
+	tests := []struct {
+		name     string
+		text     string
+		maxLines int
+		want     int
+		ellipsis bool
+	}{
+		{"fits", "Hello world", 3, 1, false},
+		{"empty", "   ", 3, 0, false},
+		{"overflows", strings.Repeat("word ", 200), 3, 3, true},
+		{"long word", strings.Repeat("W", 500), 3, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := wrap(s.titleFace, tt.text, width, tt.maxLines)
+			if len(lines) != tt.want {
+				t.Fatalf("got %d lines %q, want %d", len(lines), lines, tt.want)
+			}
+			for _, line := range lines {
+				if w := font.MeasureString(s.titleFace, line).Ceil(); w > width {
+					t.Errorf("line %q is %dpx wide, more than %dpx", line, w, width)
+				}
+			}
+			if len(lines) > 0 && strings.HasSuffix(lines[len(lines)-1], "…") != tt.ellipsis {
+				t.Errorf("last line %q, want ellipsis %v", lines[len(lines)-1], tt.ellipsis)
+			}
+		})
+	}
+}
+
+func TestPathCachesPerRevision(t *testing.T) {
+	s := newTestService(t)
+	post := &blog.Post{Blog: blog.Blog{ID: 7, Title: "Hello"}}
+
+	first, err := s.Path(post)
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	if again, err := s.Path(post); err != nil || again != first {
+		t.Errorf("Path again = %q, %v; want the cached %q", again, err, first)
+	}
+
+	// An edit renders a new image and drops the old one
+	post.Title = "Hello again"
+	second, err := s.Path(post)
+	if err != nil || second == first {
+		t.Fatalf("Path after an edit = %q, %v", second, err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("stale image %s was kept", first)
+	}
+}
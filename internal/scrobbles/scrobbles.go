@@ -0,0 +1,248 @@
+package scrobbles
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/lastfm"
+)
+
+const (
+	pageSize = 200
+
+	// Scrobblers may submit plays late (offline devices, batch uploads), so each incremental
+	// sync looks this far back past the last completed run and relies on dedupe for the overlap
+	lateScrobbleWindow = 6 * time.Hour
+
+	// Pause between pages so a long backfill doesn't trip Last.fm's rate limit
+	pageDelay = 250 * time.Millisecond
+)
+
+// Archive mirrors the user's full Last.fm scrobble history into the scrobbles table
+type Archive struct {
+	config *config.Config
+	db     *sql.DB
+	lastfm *lastfm.Service
+
+	mu sync.Mutex
+}
+
+// syncState tracks progress so an interrupted sync resumes where it stopped. A run walks
+// backwards from pendingTo, moving cursor down after every page it stores.
+type syncState struct {
+	syncedUntil int64 // every scrobble up to here has been archived
+	pendingTo   int64 // upper bound of the run in progress, 0 when idle
+	cursor      int64 // oldest scrobble stored by the run in progress
+}
+
+func NewArchive(cfg *config.Config, db *sql.DB, service *lastfm.Service) (*Archive, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS scrobbles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		played_at INTEGER NOT NULL,
+		artist TEXT NOT NULL,
+		track TEXT NOT NULL,
+		album TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL DEFAULT '',
+		UNIQUE(played_at, artist, track)
+	);
+	CREATE INDEX IF NOT EXISTS scrobbles_played_at ON scrobbles(played_at);
+	CREATE INDEX IF NOT EXISTS scrobbles_artist ON scrobbles(artist);
+	CREATE TABLE IF NOT EXISTS scrobble_sync_state (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		synced_until INTEGER NOT NULL DEFAULT 0,
+		pending_to INTEGER NOT NULL DEFAULT 0,
+		cursor INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT OR IGNORE INTO scrobble_sync_state (id) VALUES (1);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create scrobble tables: %w", err)
+	}
+
+	return &Archive{
+		config: cfg,
+		db:     db,
+		lastfm: service,
+	}, nil
+}
+
+func (a *Archive) Enabled() bool {
+	return a.config.ScrobbleSyncInterval > 0 && a.config.LastFMUsername != "" && a.config.LastFMAPIKey != ""
+}
+
+// Start catches up immediately and then every ScrobbleSyncInterval
+func (a *Archive) Start() {
+	if !a.Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(a.config.ScrobbleSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			added, err := a.Sync(context.Background())
+			if err != nil {
+				log.Printf("Scrobble sync failed: %v\n", err)
+			} else if added > 0 {
+				log.Printf("Archived %d new scrobbles\n", added)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Sync pages through user.getrecenttracks from the newest scrobble down to the end of the
+// previous run, storing each page together with its progress. It returns the number of
+// scrobbles that were new to the archive.
+func (a *Archive) Sync(ctx context.Context) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, err := a.loadState()
+	if err != nil {
+		return 0, err
+	}
+
+	if state.pendingTo == 0 {
+		state.pendingTo = time.Now().Unix()
+		state.cursor = state.pendingTo
+		if err := a.saveState(a.db, state); err != nil {
+			return 0, err
+		}
+	}
+
+	var from time.Time
+	if state.syncedUntil > 0 {
+		from = time.Unix(state.syncedUntil, 0).Add(-lateScrobbleWindow)
+	}
+
+	added := 0
+	for {
+		// Always page 1: moving `to` down to the cursor keeps the window stable even when
+		// new scrobbles arrive mid-run, and makes resuming trivial
+		page, err := a.lastfm.GetRecentTracksPage(ctx, 1, pageSize, from, time.Unix(state.cursor, 0))
+		if err != nil {
+			return added, err
+		}
+
+		oldest := state.cursor
+		n, err := a.storePage(page.Scrobbles, state, &oldest)
+		if err != nil {
+			return added, err
+		}
+		added += n
+
+		// A short page is the last one
+		if len(page.Scrobbles) < pageSize || page.TotalPages <= 1 {
+			break
+		}
+
+		// An unmoved cursor means a full page of plays in the same second, which from/to can't
+		// page past. Skip the rest of that second rather than loop or give up on older history.
+		if oldest >= state.cursor {
+			log.Printf("Scrobble archive: more than %d plays at %s, skipping the rest of that second\n",
+				pageSize, time.Unix(state.cursor, 0).UTC().Format(time.RFC3339))
+			oldest = state.cursor - 1
+		}
+		state.cursor = oldest
+
+		select {
+		case <-ctx.Done():
+			return added, ctx.Err()
+		case <-time.After(pageDelay):
+		}
+	}
+
+	state.syncedUntil = state.pendingTo
+	state.pendingTo = 0
+	state.cursor = 0
+	if err := a.saveState(a.db, state); err != nil {
+		return added, err
+	}
+
+	return added, nil
+}
+
+// storePage inserts a page of scrobbles and advances the cursor in one transaction
+func (a *Archive) storePage(page []lastfm.Scrobble, state *syncState, oldest *int64) (int, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO scrobbles (played_at, artist, track, album, url) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	added := 0
+	for _, s := range page {
+		playedAt := s.PlayedAt.Unix()
+		if playedAt < *oldest {
+			*oldest = playedAt
+		}
+
+		res, err := stmt.Exec(playedAt, s.Artist, s.Track, s.Album, s.URL)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store scrobble: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+
+	progress := *state
+	if *oldest < progress.cursor {
+		progress.cursor = *oldest
+	}
+	if err := a.saveState(tx, &progress); err != nil {
+		return 0, err
+	}
+
+	return added, tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (a *Archive) loadState() (*syncState, error) {
+	state := &syncState{}
+	err := a.db.QueryRow("SELECT synced_until, pending_to, cursor FROM scrobble_sync_state WHERE id = 1").
+		Scan(&state.syncedUntil, &state.pendingTo, &state.cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scrobble sync state: %w", err)
+	}
+	return state, nil
+}
+
+func (a *Archive) saveState(db execer, state *syncState) error {
+	_, err := db.Exec("UPDATE scrobble_sync_state SET synced_until = ?, pending_to = ?, cursor = ? WHERE id = 1",
+		state.syncedUntil, state.pendingTo, state.cursor)
+	if err != nil {
+		return fmt.Errorf("failed to save scrobble sync state: %w", err)
+	}
+	return nil
+}
+
+// HasData reports whether anything has been archived yet, so callers can fall back to Last.fm
+func (a *Archive) HasData() bool {
+	var exists bool
+	err := a.db.QueryRow("SELECT EXISTS (SELECT 1 FROM scrobbles)").Scan(&exists)
+	return err == nil && exists
+}
+
+// Complete reports whether a full sync has finished, so the archive holds the whole history
+// rather than the newest part of a backfill still in progress
+func (a *Archive) Complete() bool {
+	state, err := a.loadState()
+	return err == nil && state.syncedUntil > 0
+}
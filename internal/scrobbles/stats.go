@@ -0,0 +1,348 @@
+package scrobbles
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range limits a query to scrobbles in [From, To]. Day boundaries, hours and weekdays are
+// taken in Location, including across daylight saving changes.
+type Range struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+var ErrInvalidInterval = errors.New("invalid interval, expected day, week or month")
+
+type PeriodCount struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+}
+
+type Heatmap struct {
+	// Weekdays[i] names row i of Counts, starting on Sunday
+	Weekdays []string `json:"weekdays"`
+	// Counts[weekday][hour]
+	Counts [7][24]int `json:"counts"`
+}
+
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type Streaks struct {
+	Current Streak `json:"current"`
+	Longest Streak `json:"longest"`
+}
+
+type FirstListen struct {
+	Artist      string    `json:"artist"`
+	FirstListen time.Time `json:"firstListen"`
+	PlayCount   int       `json:"playcount"`
+}
+
+type Counts struct {
+	Scrobbles       int `json:"scrobbles"`
+	DistinctArtists int `json:"distinctArtists"`
+	DistinctTracks  int `json:"distinctTracks"`
+	DistinctAlbums  int `json:"distinctAlbums"`
+}
+
+// ParseRange reads from/to query values as RFC3339, YYYY-MM-DD or unix seconds, and tz as an
+// IANA zone name. Missing bounds leave the range open; a date-only `to` includes that whole day.
+func ParseRange(from, to, tz string) (Range, error) {
+	r := Range{Location: time.UTC}
+
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return r, fmt.Errorf("invalid tz %q", tz)
+		}
+		r.Location = loc
+	}
+
+	var err error
+	if from != "" {
+		if r.From, _, err = parseTime(from, r.Location); err != nil {
+			return r, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if to != "" {
+		var dateOnly bool
+		if r.To, dateOnly, err = parseTime(to, r.Location); err != nil {
+			return r, fmt.Errorf("invalid to: %w", err)
+		}
+		if dateOnly {
+			r.To = r.To.AddDate(0, 0, 1).Add(-time.Second)
+		}
+	}
+
+	if !r.From.IsZero() && !r.To.IsZero() && r.To.Before(r.From) {
+		return r, fmt.Errorf("to is before from")
+	}
+	return r, nil
+}
+
+func parseTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, loc); err == nil {
+		return t, true, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not RFC3339, YYYY-MM-DD or a unix timestamp", value)
+}
+
+// bounds returns the range as unix seconds, open ends becoming the widest possible values
+func (r Range) bounds() (int64, int64) {
+	from, to := int64(0), int64(1<<62)
+	if !r.From.IsZero() {
+		from = r.From.Unix()
+	}
+	if !r.To.IsZero() {
+		to = r.To.Unix()
+	}
+	return from, to
+}
+
+// quarterHour is how finely scrobbles are counted before bucketing. Every zone offset and
+// daylight saving change falls on a quarter hour, so these counts split exactly into local
+// days and hours.
+const quarterHour = 15 * 60
+
+// eachQuarterHour calls fn with the local start of every quarter hour in the range that has
+// scrobbles, oldest first, and how many it has
+func (a *Archive) eachQuarterHour(r Range, fn func(start time.Time, count int)) error {
+	from, to := r.bounds()
+	rows, err := a.db.Query(`
+		SELECT played_at / ? AS quarter, COUNT(*)
+		FROM scrobbles WHERE played_at BETWEEN ? AND ?
+		GROUP BY quarter ORDER BY quarter`,
+		quarterHour, from, to,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var quarter int64
+		var count int
+		if err := rows.Scan(&quarter, &count); err != nil {
+			return err
+		}
+		fn(time.Unix(quarter*quarterHour, 0).In(r.Location), count)
+	}
+	return rows.Err()
+}
+
+// ScrobblesPer counts scrobbles per day, week (starting Monday) or month
+func (a *Archive) ScrobblesPer(interval string, r Range) ([]PeriodCount, error) {
+	var bucket func(t time.Time) string
+	switch interval {
+	case "day":
+		bucket = func(t time.Time) string { return t.Format(time.DateOnly) }
+	case "week":
+		bucket = func(t time.Time) string {
+			return t.AddDate(0, 0, -(int(t.Weekday())+6)%7).Format(time.DateOnly)
+		}
+	case "month":
+		bucket = func(t time.Time) string { return t.Format("2006-01") }
+	default:
+		return nil, ErrInvalidInterval
+	}
+
+	// Quarter hours come oldest first, so periods do too
+	counts := make([]PeriodCount, 0)
+	err := a.eachQuarterHour(r, func(start time.Time, count int) {
+		period := bucket(start)
+		if n := len(counts); n > 0 && counts[n-1].Period == period {
+			counts[n-1].Count += count
+			return
+		}
+		counts = append(counts, PeriodCount{Period: period, Count: count})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Heatmap counts scrobbles by weekday and hour of day
+func (a *Archive) Heatmap(r Range) (*Heatmap, error) {
+	heatmap := &Heatmap{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		heatmap.Weekdays = append(heatmap.Weekdays, d.String())
+	}
+
+	err := a.eachQuarterHour(r, func(start time.Time, count int) {
+		heatmap.Counts[start.Weekday()][start.Hour()] += count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return heatmap, nil
+}
+
+// Streaks finds runs of consecutive days with at least one scrobble. The current streak is
+// the one ending today or yesterday, since today may not have any plays yet.
+func (a *Archive) Streaks(r Range) (*Streaks, error) {
+	days := make([]time.Time, 0)
+	err := a.eachQuarterHour(r, func(start time.Time, _ int) {
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if n := len(days); n == 0 || !days[n-1].Equal(day) {
+			days = append(days, day)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(r.Location)
+	today := now.Format(time.DateOnly)
+	yesterday := now.AddDate(0, 0, -1).Format(time.DateOnly)
+
+	streaks := &Streaks{}
+	for i := 0; i < len(days); {
+		// Days are kept as UTC dates, so consecutive ones are exactly a day apart
+		j := i + 1
+		for j < len(days) && days[j].Sub(days[j-1]) == 24*time.Hour {
+			j++
+		}
+
+		s := Streak{Days: j - i, Start: days[i].Format(time.DateOnly), End: days[j-1].Format(time.DateOnly)}
+		if s.Days > streaks.Longest.Days {
+			streaks.Longest = s
+		}
+		if s.End == today || s.End == yesterday {
+			streaks.Current = s
+		}
+		i = j
+	}
+	return streaks, nil
+}
+
+// artistFirsts returns every artist with their first play and total plays, matching names
+// ignoring case and naming each as in their latest play. SQLite's lower() only folds ASCII,
+// so spellings are merged here rather than in the query.
+func (a *Archive) artistFirsts() ([]FirstListen, error) {
+	rows, err := a.db.Query("SELECT artist, MIN(played_at), MAX(played_at), COUNT(*) FROM scrobbles GROUP BY artist")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type spelling struct {
+		listen      FirstListen
+		first, last int64
+	}
+
+	artists := make(map[string]*spelling)
+	for rows.Next() {
+		var (
+			artist      string
+			first, last int64
+			plays       int
+		)
+		if err := rows.Scan(&artist, &first, &last, &plays); err != nil {
+			return nil, err
+		}
+
+		key := strings.ToLower(artist)
+		merged, ok := artists[key]
+		if !ok {
+			artists[key] = &spelling{listen: FirstListen{Artist: artist, PlayCount: plays}, first: first, last: last}
+			continue
+		}
+		merged.listen.PlayCount += plays
+		merged.first = min(merged.first, first)
+		if last > merged.last {
+			merged.listen.Artist, merged.last = artist, last
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	listens := make([]FirstListen, 0, len(artists))
+	for _, merged := range artists {
+		merged.listen.FirstListen = time.Unix(merged.first, 0).UTC()
+		listens = append(listens, merged.listen)
+	}
+	return listens, nil
+}
+
+// FirstListens lists artists whose first ever scrobble falls inside the range, newest first
+func (a *Archive) FirstListens(r Range, limit int) ([]FirstListen, error) {
+	artists, err := a.artistFirsts()
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := r.bounds()
+	listens := make([]FirstListen, 0)
+	for _, l := range artists {
+		if first := l.FirstListen.Unix(); first < from || first > to {
+			continue
+		}
+		l.FirstListen = l.FirstListen.In(r.Location)
+		listens = append(listens, l)
+	}
+	sort.Slice(listens, func(i, j int) bool { return listens[i].FirstListen.After(listens[j].FirstListen) })
+
+	if limit >= 0 && len(listens) > limit {
+		listens = listens[:limit]
+	}
+	return listens, nil
+}
+
+// Discoveries lists artists whose first ever scrobble is at or after since, most played first.
+// Every play of such an artist comes after their discovery, so PlayCount is plays since then.
+func (a *Archive) Discoveries(since time.Time) ([]FirstListen, error) {
+	artists, err := a.artistFirsts()
+	if err != nil {
+		return nil, err
+	}
+
+	listens := make([]FirstListen, 0)
+	for _, l := range artists {
+		if l.FirstListen.Unix() >= since.Unix() {
+			listens = append(listens, l)
+		}
+	}
+	sort.Slice(listens, func(i, j int) bool {
+		if listens[i].PlayCount != listens[j].PlayCount {
+			return listens[i].PlayCount > listens[j].PlayCount
+		}
+		return listens[i].FirstListen.After(listens[j].FirstListen)
+	})
+	return listens, nil
+}
+
+// Counts totals scrobbles and distinct artists, tracks and albums in the range
+func (a *Archive) Counts(r Range) (*Counts, error) {
+	from, to := r.bounds()
+	c := &Counts{}
+	err := a.db.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(DISTINCT artist),
+		       COUNT(DISTINCT artist || char(31) || track),
+		       COUNT(DISTINCT CASE WHEN album != '' THEN artist || char(31) || album END)
+		FROM scrobbles WHERE played_at BETWEEN ? AND ?`,
+		from, to,
+	).Scan(&c.Scrobbles, &c.DistinctArtists, &c.DistinctTracks, &c.DistinctAlbums)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
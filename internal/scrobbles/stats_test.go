@@ -0,0 +1,167 @@
+package scrobbles
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"tringldev-server/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+// play is a scrobble inserted into the test archive
+type play struct {
+	at     string // RFC3339
+	artist string
+}
+
+func newTestArchive(t *testing.T, plays []play) *Archive {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	a, err := NewArchive(&config.Config{}, db, nil)
+	if err != nil {
+		t.Fatalf("NewArchive: %v", err)
+	}
+	for i, p := range plays {
+		at, err := time.Parse(time.RFC3339, p.at)
+		if err != nil {
+			t.Fatalf("play %d: %v", i, err)
+		}
+		if _, err := db.Exec("INSERT INTO scrobbles (played_at, artist, track) VALUES (?, ?, ?)", at.Unix(), p.artist, "Track"); err != nil {
+			t.Fatalf("inserting play %d: %v", i, err)
+		}
+	}
+	return a
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%s): %v", name, err)
+	}
+	return loc
+}
+
+func TestBucketsAcrossDaylightSaving(t *testing.T) {
+	// Berlin leaves summer time on 2024-10-27, so the offset is +2 before and +1 after
+	a := newTestArchive(t, []play{
+		{"2024-10-20T22:30:00Z", "Burial"}, // 00:30 on Monday 21st in summer time
+		{"2024-10-27T22:30:00Z", "Burial"}, // 23:30 on Sunday 27th in winter time
+		{"2024-10-28T10:00:00Z", "Burial"}, // 11:00 on Monday 28th
+	})
+	r := Range{
+		From:     time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC),
+		Location: mustLocation(t, "Europe/Berlin"),
+	}
+
+	days, err := a.ScrobblesPer("day", r)
+	if err != nil {
+		t.Fatalf("ScrobblesPer: %v", err)
+	}
+	wantDays := []PeriodCount{{"2024-10-21", 1}, {"2024-10-27", 1}, {"2024-10-28", 1}}
+	if len(days) != len(wantDays) {
+		t.Fatalf("days = %+v, want %+v", days, wantDays)
+	}
+	for i := range wantDays {
+		if days[i] != wantDays[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], wantDays[i])
+		}
+	}
+
+	weeks, err := a.ScrobblesPer("week", r)
+	if err != nil {
+		t.Fatalf("ScrobblesPer: %v", err)
+	}
+	if len(weeks) != 2 || weeks[0] != (PeriodCount{"2024-10-21", 2}) || weeks[1] != (PeriodCount{"2024-10-28", 1}) {
+		t.Errorf("weeks = %+v, want two plays in the week of the 21st and one in the week of the 28th", weeks)
+	}
+
+	heatmap, err := a.Heatmap(r)
+	if err != nil {
+		t.Fatalf("Heatmap: %v", err)
+	}
+	if heatmap.Counts[time.Monday][0] != 1 || heatmap.Counts[time.Sunday][23] != 1 || heatmap.Counts[time.Monday][11] != 1 {
+		t.Errorf("unexpected heatmap: %v", heatmap.Counts)
+	}
+}
+
+func TestBucketsInQuarterHourZones(t *testing.T) {
+	// Kathmandu is UTC+5:45
+	a := newTestArchive(t, []play{
+		{"2024-01-01T18:14:59Z", "Burial"}, // 23:59:59 on the 1st
+		{"2024-01-01T18:15:00Z", "Burial"}, // midnight on the 2nd
+		{"2024-01-03T18:20:00Z", "Burial"}, // 00:05 on the 4th, after a day without plays
+	})
+	r := Range{Location: mustLocation(t, "Asia/Kathmandu")}
+
+	days, err := a.ScrobblesPer("day", r)
+	if err != nil {
+		t.Fatalf("ScrobblesPer: %v", err)
+	}
+	if len(days) != 3 || days[0].Period != "2024-01-01" || days[1].Period != "2024-01-02" || days[2].Period != "2024-01-04" {
+		t.Errorf("days = %+v", days)
+	}
+
+	streaks, err := a.Streaks(r)
+	if err != nil {
+		t.Fatalf("Streaks: %v", err)
+	}
+	want := Streak{Days: 2, Start: "2024-01-01", End: "2024-01-02"}
+	if streaks.Longest != want || streaks.Current != (Streak{}) {
+		t.Errorf("streaks = %+v, want longest %+v and no current streak", streaks, want)
+	}
+}
+
+func TestFirstListensIgnoreCase(t *testing.T) {
+	a := newTestArchive(t, []play{
+		{"2024-01-05T12:00:00Z", "burial"},
+		{"2024-02-01T12:00:00Z", "Burial"},
+		{"2024-03-01T12:00:00Z", "BURIAL"},
+		{"2024-02-10T12:00:00Z", "Four Tet"},
+		{"2023-12-01T12:00:00Z", "Aphex Twin"},
+		{"2024-02-20T12:00:00Z", "aphex twin"},
+	})
+
+	r := Range{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Location: time.UTC}
+	listens, err := a.FirstListens(r, 10)
+	if err != nil {
+		t.Fatalf("FirstListens: %v", err)
+	}
+	// Aphex Twin was first played before the range, whatever the spelling
+	want := []FirstListen{
+		{Artist: "Four Tet", FirstListen: time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC), PlayCount: 1},
+		{Artist: "BURIAL", FirstListen: time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC), PlayCount: 3},
+	}
+	if len(listens) != len(want) {
+		t.Fatalf("first listens = %+v, want %+v", listens, want)
+	}
+	for i := range want {
+		if listens[i] != want[i] {
+			t.Errorf("first listen %d = %+v, want %+v", i, listens[i], want[i])
+		}
+	}
+
+	discoveries, err := a.Discoveries(r.From)
+	if err != nil {
+		t.Fatalf("Discoveries: %v", err)
+	}
+	if len(discoveries) != 2 || discoveries[0] != want[1] || discoveries[1] != want[0] {
+		t.Errorf("discoveries = %+v, want the same artists most played first", discoveries)
+	}
+
+	if limited, err := a.FirstListens(r, 1); err != nil || len(limited) != 1 || limited[0].Artist != "Four Tet" {
+		t.Errorf("FirstListens limited to 1 = %+v, %v", limited, err)
+	}
+}
@@ -0,0 +1,134 @@
+package webmention
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"tringldev-server/internal/blog"
+
+	"golang.org/x/net/html"
+)
+
+// Start runs the background worker that verifies received webmentions
+func (s *Service) Start() {
+	go func() {
+		for m := range s.queue {
+			if err := s.verify(m); err != nil {
+				log.Printf("Failed to verify webmention from %s: %v\n", m.source, err)
+			}
+		}
+	}()
+}
+
+// Receive validates a webmention request and queues it for asynchronous verification
+func (s *Service) Receive(source, target string) error {
+	if s.config.SiteURL == "" {
+		return ErrDisabled
+	}
+
+	sourceURL, err := parseHTTPURL(source)
+	if err != nil {
+		return fmt.Errorf("%w: source: %v", ErrInvalidMention, err)
+	}
+	targetURL, err := parseHTTPURL(target)
+	if err != nil {
+		return fmt.Errorf("%w: target: %v", ErrInvalidMention, err)
+	}
+	if sourceURL.String() == targetURL.String() {
+		return fmt.Errorf("%w: source and target are the same", ErrInvalidMention)
+	}
+
+	postID, ok := s.postIDFromTarget(targetURL)
+	if !ok {
+		return fmt.Errorf("%w: target is not a blog post on this site", ErrInvalidMention)
+	}
+	if _, err := blog.GetBlogByID(postID); err != nil {
+		return fmt.Errorf("%w: target post does not exist", ErrInvalidMention)
+	}
+
+	select {
+	case s.queue <- mention{source: sourceURL.String(), target: targetURL.String(), postID: postID}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// verify fetches the source and stores or removes the mention depending on whether it still links to the target
+func (s *Service) verify(m mention) error {
+	req, err := http.NewRequest("GET", m.source, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch source: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// A deleted source means the mention should go too
+	if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
+		return blog.DeleteWebmention(m.source, m.target)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("source returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+
+	var found bool
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		found = htmlLinksTo(string(body), resp.Request.URL, m.target)
+	} else {
+		found = strings.Contains(string(body), m.target)
+	}
+
+	if !found {
+		return blog.DeleteWebmention(m.source, m.target)
+	}
+
+	return blog.SaveWebmention(m.postID, m.source, m.target)
+}
+
+// htmlLinksTo reports whether any href or src in the document resolves to target
+func htmlLinksTo(doc string, base *url.URL, target string) bool {
+	tokenizer := html.NewTokenizer(strings.NewReader(doc))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			for {
+				key, val, more := tokenizer.TagAttr()
+				k := string(key)
+				if (k == "href" || k == "src") && resolve(base, string(val)) == target {
+					return true
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+}
+
+func resolve(base *url.URL, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	if base == nil {
+		return u.String()
+	}
+	return base.ResolveReference(u).String()
+}
@@ -0,0 +1,194 @@
+package webmention
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/blog"
+
+	"golang.org/x/net/html"
+)
+
+var linkPattern = regexp.MustCompile("https?://[^\\s<>()\\[\\]\"'`]+")
+
+// ExtractLinks returns the unique absolute http(s) links in a markdown document, in order of appearance
+func ExtractLinks(markdown string) []string {
+	seen := make(map[string]bool)
+	links := make([]string, 0)
+
+	for _, match := range linkPattern.FindAllString(markdown, -1) {
+		link := strings.TrimRight(match, ".,;:!?*_~")
+		if _, err := parseHTTPURL(link); err != nil {
+			continue
+		}
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// SendForPost notifies every site linked from a post that it was mentioned
+func (s *Service) SendForPost(post *blog.Post) {
+	if s.config.SiteURL == "" {
+		return
+	}
+
+	source := blog.PostURL(s.config.SiteURL, post.ID)
+	site, _ := url.Parse(s.config.SiteURL)
+
+	for _, target := range ExtractLinks(post.Markdown) {
+		if u, err := url.Parse(target); err == nil && site != nil && strings.EqualFold(u.Host, site.Host) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.Send(ctx, source, target)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to send webmention to %s: %v\n", target, err)
+		}
+	}
+}
+
+// Send discovers the webmention endpoint of target and notifies it about source.
+// Targets without an endpoint are skipped silently.
+func (s *Service) Send(ctx context.Context, source, target string) error {
+	endpoint, err := s.Discover(ctx, target)
+	if err != nil {
+		return err
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	form := url.Values{}
+	form.Set("source", source)
+	form.Set("target", target)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webmention: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webmention endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// Discover finds the webmention endpoint advertised by target, first in its Link headers and
+// then in <link> or <a> elements. It returns an empty string when there is none.
+func (s *Service) Discover(ctx context.Context, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch target: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("target returned status %d", resp.StatusCode)
+	}
+
+	base := resp.Request.URL
+
+	for _, header := range resp.Header.Values("Link") {
+		if href, ok := webmentionFromLinkHeader(header); ok {
+			return resolve(base, href), nil
+		}
+	}
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return "", nil
+	}
+
+	tokenizer := html.NewTokenizer(io.LimitReader(resp.Body, maxBodySize))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return "", nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			tag := string(name)
+			if !hasAttr || (tag != "link" && tag != "a") {
+				continue
+			}
+
+			var rel, href string
+			var hasHref bool
+			for {
+				key, val, more := tokenizer.TagAttr()
+				switch string(key) {
+				case "rel":
+					rel = string(val)
+				case "href":
+					href, hasHref = string(val), true
+				}
+				if !more {
+					break
+				}
+			}
+
+			// An empty href is valid and means the target itself
+			if hasHref && hasRel(rel, "webmention") {
+				return resolve(base, href), nil
+			}
+		}
+	}
+}
+
+// webmentionFromLinkHeader parses a header like `<https://example.com/wm>; rel="webmention"`
+func webmentionFromLinkHeader(header string) (string, bool) {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		href := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(href, "<") || !strings.HasSuffix(href, ">") {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+				continue
+			}
+			if hasRel(strings.Trim(strings.TrimSpace(val), `"`), "webmention") {
+				return href[1 : len(href)-1], true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasRel(rel, want string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, want) {
+			return true
+		}
+	}
+	return false
+}
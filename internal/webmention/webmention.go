@@ -0,0 +1,120 @@
+package webmention
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"tringldev-server/internal/config"
+)
+
+const (
+	queueSize   = 100
+	maxBodySize = 1 << 20 // 1MB is plenty to find a link in
+	userAgent   = "tringldev-server webmention (+https://tringl.dev)"
+)
+
+var (
+	ErrInvalidMention = errors.New("invalid webmention")
+	ErrQueueFull      = errors.New("webmention queue is full")
+	ErrDisabled       = errors.New("webmentions are disabled (set SITE_URL)")
+
+	errForbiddenAddress = errors.New("refusing to connect to a private address")
+)
+
+type Service struct {
+	config *config.Config
+	client *http.Client
+	queue  chan mention
+}
+
+type mention struct {
+	source string
+	target string
+	postID int
+}
+
+// NewService creates a webmention sender/receiver. All outgoing HTTP goes through client,
+// which defaults to NewSafeClient when nil.
+func NewService(cfg *config.Config, client *http.Client) *Service {
+	if client == nil {
+		client = NewSafeClient()
+	}
+
+	return &Service{
+		config: cfg,
+		client: client,
+		queue:  make(chan mention, queueSize),
+	}
+}
+
+// NewSafeClient returns an HTTP client that won't connect to loopback or private networks,
+// since the URLs it fetches come from strangers on the internet
+func NewSafeClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return errForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// postIDFromTarget extracts the post id from one of our permalinks
+func (s *Service) postIDFromTarget(target *url.URL) (int, bool) {
+	site, err := url.Parse(s.config.SiteURL)
+	if err != nil || site.Host == "" {
+		return 0, false
+	}
+
+	if !strings.EqualFold(target.Host, site.Host) {
+		return 0, false
+	}
+
+	prefix := strings.TrimSuffix(site.Path, "/") + "/blog/"
+	if !strings.HasPrefix(target.Path, prefix) {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(target.Path, prefix), "/"))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+func parseHTTPURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	return u, nil
+}
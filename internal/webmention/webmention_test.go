@@ -0,0 +1,231 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"tringldev-server/internal/blog"
+	"tringldev-server/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+const siteURL = "https://blog.example"
+
+// newTestService returns a service for siteURL with a fresh blog database holding one post.
+// Tests reach httptest servers on loopback, so they pass the default client.
+func newTestService(t *testing.T) (*Service, *blog.Post) {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	if err := blog.InitDatabase(); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	t.Cleanup(func() { blog.DB.Close() })
+
+	post, err := blog.CreatePost("Hello", "First post", "Hello world")
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	return NewService(&config.Config{SiteURL: siteURL}, http.DefaultClient), post
+}
+
+func TestDiscover(t *testing.T) {
+	mux := http.NewServeMux()
+	page := func(path, contentType, link, body string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if link != "" {
+				w.Header().Set("Link", link)
+			}
+			w.Header().Set("Content-Type", contentType)
+			fmt.Fprint(w, body)
+		})
+	}
+	page("/header", "text/html", `<https://other.example/a>; rel="other", </endpoint>; rel="webmention"`, `<link rel="webmention" href="/ignored">`)
+	page("/header-unquoted", "text/plain", `<https://wm.example/in>; rel=webmention`, "")
+	page("/link", "text/html; charset=utf-8", "", `<html><head><link rel="stylesheet" href="/style.css"><link rel="me webmention" href="wm?x=1"></head></html>`)
+	page("/anchor", "text/html", "", `<p><a href="/nope">no</a> <a rel="webmention" href="https://wm.example/a">wm</a></p>`)
+	page("/empty-href", "text/html", "", `<link rel="webmention" href="">`)
+	page("/none", "text/html", "", `<a href="/endpoint">not advertised</a>`)
+	page("/plain", "text/plain", "", `<link rel="webmention" href="/endpoint">`)
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/sub/link", http.StatusFound)
+	})
+	page("/sub/link", "text/html", "", `<link rel="webmention" href="endpoint">`)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/header", server.URL + "/endpoint"},
+		{"/header-unquoted", "https://wm.example/in"},
+		{"/link", server.URL + "/wm?x=1"},
+		{"/anchor", "https://wm.example/a"},
+		{"/empty-href", server.URL + "/empty-href"},
+		{"/none", ""},
+		{"/plain", ""},
+		// Relative endpoints resolve against the final URL after redirects
+		{"/redirect", server.URL + "/sub/endpoint"},
+	}
+
+	s := NewService(&config.Config{SiteURL: siteURL}, http.DefaultClient)
+	for _, tt := range tests {
+		got, err := s.Discover(context.Background(), server.URL+tt.path)
+		if err != nil {
+			t.Errorf("Discover(%s): %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Discover(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := s.Discover(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("Discover of a 404 page succeeded")
+	}
+}
+
+func TestSendForPost(t *testing.T) {
+	s, _ := newTestService(t)
+
+	received := make(chan url.Values, 10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</webmention>; rel="webmention"`)
+		w.Header().Set("Content-Type", "text/html")
+	})
+	mux.HandleFunc("/no-endpoint", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<p>nothing here</p>")
+	})
+	mux.HandleFunc("/webmention", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		r.ParseForm()
+		received <- r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	post := &blog.Post{Blog: blog.Blog{ID: 7}, Markdown: fmt.Sprintf(
+		"See [this](%[1]s/article), [that](%[1]s/no-endpoint) and [my other post](%[2]s/blog/1).", server.URL, siteURL)}
+	s.SendForPost(post)
+
+	close(received)
+	var forms []url.Values
+	for form := range received {
+		forms = append(forms, form)
+	}
+	if len(forms) != 1 {
+		t.Fatalf("endpoint received %d webmentions, want 1: %v", len(forms), forms)
+	}
+	if forms[0].Get("source") != siteURL+"/blog/7" || forms[0].Get("target") != server.URL+"/article" {
+		t.Errorf("unexpected webmention: %v", forms[0])
+	}
+}
+
+func TestReceive(t *testing.T) {
+	s, post := newTestService(t)
+	target := blog.PostURL(siteURL, post.ID)
+
+	tests := []struct {
+		name, source, target string
+	}{
+		{"source scheme", "ftp://example.com/a", target},
+		{"target scheme", "https://example.com/a", "javascript:alert(1)"},
+		{"same url", target, target},
+		{"other site", "https://example.com/a", "https://elsewhere.example/blog/1"},
+		{"not a post", "https://example.com/a", siteURL + "/about"},
+		{"missing post", "https://example.com/a", siteURL + "/blog/999"},
+	}
+	for _, tt := range tests {
+		if err := s.Receive(tt.source, tt.target); !errors.Is(err, ErrInvalidMention) {
+			t.Errorf("%s: error = %v, want ErrInvalidMention", tt.name, err)
+		}
+	}
+
+	if err := s.Receive("https://example.com/a", target+"/"); err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	m := <-s.queue
+	if m.postID != post.ID || m.source != "https://example.com/a" {
+		t.Errorf("queued %+v", m)
+	}
+
+	disabled := NewService(&config.Config{}, http.DefaultClient)
+	if err := disabled.Receive("https://example.com/a", target); !errors.Is(err, ErrDisabled) {
+		t.Errorf("error without SITE_URL = %v, want ErrDisabled", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s, post := newTestService(t)
+	target := blog.PostURL(siteURL, post.ID)
+
+	var status int
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+	source := server.URL + "/reply"
+
+	steps := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantStored  bool
+	}{
+		{"links to the post", 200, "text/html", `<p>Replying to <a href="` + target + `">this</a></p>`, true},
+		{"link removed", 200, "text/html", `<p>Replying to <a href="` + siteURL + `/blog/999">that</a></p>`, false},
+		{"target only in text", 200, "text/html", `<p>` + target + `</p>`, false},
+		{"plain text mention", 200, "text/plain", "Replying to " + target, true},
+		{"source deleted", http.StatusGone, "text/html", "", false},
+	}
+
+	for _, step := range steps {
+		status, contentType, body = step.status, step.contentType, step.body
+		if err := s.verify(mention{source: source, target: target, postID: post.ID}); err != nil {
+			t.Fatalf("%s: verify: %v", step.name, err)
+		}
+
+		mentions, err := blog.GetWebmentions(post.ID)
+		if err != nil {
+			t.Fatalf("GetWebmentions: %v", err)
+		}
+		if stored := len(mentions) == 1; stored != step.wantStored {
+			t.Errorf("%s: stored = %v, want %v", step.name, stored, step.wantStored)
+		}
+	}
+
+	status = http.StatusInternalServerError
+	if err := s.verify(mention{source: source, target: target, postID: post.ID}); err == nil {
+		t.Error("verify succeeded against a failing source")
+	}
+}
+
+func TestSafeClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("safe client reached a loopback server")
+	}))
+	defer server.Close()
+
+	s := NewService(&config.Config{SiteURL: siteURL}, nil)
+	if _, err := s.Discover(context.Background(), server.URL); !errors.Is(err, errForbiddenAddress) {
+		t.Errorf("error = %v, want errForbiddenAddress", err)
+	}
+}
@@ -0,0 +1,289 @@
+package wrapped
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tringldev-server/internal/lastfm"
+	"tringldev-server/internal/scrobbles"
+)
+
+const (
+	topLimit = 10
+
+	// Last.fm doesn't report track lengths in charts, so listening time is estimated
+	averageTrackMinutes = 3.5
+)
+
+type Report struct {
+	Year int `json:"year"`
+	// False while the year, or its last chart week, is still going
+	Complete         bool      `json:"complete"`
+	GeneratedAt      time.Time `json:"generatedAt"`
+	TotalScrobbles   int       `json:"totalScrobbles"`
+	EstimatedMinutes int       `json:"estimatedMinutes"`
+
+	TopArtists []lastfm.ChartEntry `json:"topArtists"`
+	TopTracks  []lastfm.ChartEntry `json:"topTracks"`
+	TopAlbums  []lastfm.ChartEntry `json:"topAlbums"`
+	// Most played artists with no plays in any earlier year
+	TopDiscoveries []lastfm.ChartEntry `json:"topDiscoveries"`
+
+	BusiestWeek *WeekCount `json:"busiestWeek"`
+	// Only known when the scrobble archive covers the year, charts are weekly at best
+	BusiestDay *DayCount    `json:"busiestDay"`
+	Months     []MonthCount `json:"months"`
+	// Genres whose share changed most between the first and second half of the year
+	GenreShift []GenreShift `json:"genreShift"`
+}
+
+type WeekCount struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Scrobbles int       `json:"scrobbles"`
+}
+
+type DayCount struct {
+	Date      string `json:"date"`
+	Scrobbles int    `json:"scrobbles"`
+}
+
+type MonthCount struct {
+	Month     string `json:"month"`
+	Scrobbles int    `json:"scrobbles"`
+}
+
+type GenreShift struct {
+	Name       string  `json:"name"`
+	FirstHalf  float64 `json:"firstHalf"`
+	SecondHalf float64 `json:"secondHalf"`
+	Change     float64 `json:"change"`
+}
+
+// tally sums chart entries across weeks
+type tally map[string]*lastfm.ChartEntry
+
+func (t tally) add(entries []lastfm.ChartEntry) {
+	for _, e := range entries {
+		key := strings.ToLower(e.Artist) + "\x1f" + strings.ToLower(e.Name)
+		if existing, ok := t[key]; ok {
+			existing.PlayCount += e.PlayCount
+			continue
+		}
+		entry := e
+		t[key] = &entry
+	}
+}
+
+// top returns the n most played entries that pass keep, ties broken by name
+func (t tally) top(n int, keep func(lastfm.ChartEntry) bool) []lastfm.ChartEntry {
+	entries := make([]lastfm.ChartEntry, 0, len(t))
+	for _, e := range t {
+		if keep == nil || keep(*e) {
+			entries = append(entries, *e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].PlayCount != entries[j].PlayCount {
+			return entries[i].PlayCount > entries[j].PlayCount
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// build assembles a report from the weekly charts of year. Chart weeks run Sunday to Sunday,
+// so each belongs to the year and month its midpoint falls in.
+func (s *Service) build(ctx context.Context, year int) (*Report, error) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+
+	ranges, err := s.lastfm.GetWeeklyChartList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var weeks []lastfm.ChartRange
+	for _, r := range ranges {
+		mid := midpoint(r)
+		if !mid.Before(start) && mid.Before(end) {
+			weeks = append(weeks, r)
+		}
+	}
+
+	report := &Report{
+		Year:        year,
+		GeneratedAt: time.Now().UTC(),
+		Months:      make([]MonthCount, 12),
+	}
+	for m := range report.Months {
+		report.Months[m].Month = time.Date(year, time.Month(m+1), 1, 0, 0, 0, 0, time.UTC).Format("2006-01")
+	}
+
+	// Complete a week after the year's last chart week has ended, leaving time for late scrobbles
+	lastWeekEnd := end
+	if len(weeks) > 0 {
+		lastWeekEnd = weeks[len(weeks)-1].To
+	}
+	report.Complete = time.Now().After(end) && time.Now().After(lastWeekEnd.Add(7*24*time.Hour))
+
+	artists, tracks, albums := tally{}, tally{}, tally{}
+	halves := [2]tally{{}, {}}
+
+	for _, week := range weeks {
+		artistChart, err := s.chart(ctx, "artist", week.From, week.To)
+		if err != nil {
+			return nil, err
+		}
+		trackChart, err := s.chart(ctx, "track", week.From, week.To)
+		if err != nil {
+			return nil, err
+		}
+		albumChart, err := s.chart(ctx, "album", week.From, week.To)
+		if err != nil {
+			return nil, err
+		}
+
+		artists.add(artistChart)
+		tracks.add(trackChart)
+		albums.add(albumChart)
+
+		mid := midpoint(week)
+		half := 0
+		if mid.Month() > time.June {
+			half = 1
+		}
+		halves[half].add(artistChart)
+
+		total := 0
+		for _, e := range artistChart {
+			total += e.PlayCount
+		}
+		report.TotalScrobbles += total
+		report.Months[mid.Month()-1].Scrobbles += total
+
+		if total > 0 && (report.BusiestWeek == nil || total > report.BusiestWeek.Scrobbles) {
+			report.BusiestWeek = &WeekCount{From: week.From, To: week.To, Scrobbles: total}
+		}
+	}
+
+	report.EstimatedMinutes = int(math.Round(float64(report.TotalScrobbles) * averageTrackMinutes))
+	report.TopArtists = artists.top(topLimit, nil)
+	report.TopTracks = tracks.top(topLimit, nil)
+	report.TopAlbums = albums.top(topLimit, nil)
+
+	if report.TopDiscoveries, err = s.discoveries(ctx, ranges, weeks, artists); err != nil {
+		return nil, err
+	}
+	report.BusiestDay = s.busiestDay(start, end, report.TotalScrobbles)
+	report.GenreShift = s.genreShift(ctx, halves)
+
+	return report, nil
+}
+
+func midpoint(r lastfm.ChartRange) time.Time {
+	return r.From.Add(r.To.Sub(r.From) / 2)
+}
+
+// discoveries picks the year's artists that never appear before its first week, using one
+// artist chart spanning the whole history before it
+func (s *Service) discoveries(ctx context.Context, ranges, weeks []lastfm.ChartRange, artists tally) ([]lastfm.ChartEntry, error) {
+	if len(weeks) == 0 {
+		return []lastfm.ChartEntry{}, nil
+	}
+
+	known := make(map[string]bool)
+	if first := ranges[0].From; first.Before(weeks[0].From) {
+		before, err := s.chart(ctx, "artist", first, weeks[0].From)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range before {
+			known[strings.ToLower(e.Name)] = true
+		}
+	}
+
+	return artists.top(topLimit, func(e lastfm.ChartEntry) bool {
+		return !known[strings.ToLower(e.Name)]
+	}), nil
+}
+
+// busiestDay reads the year's busiest day from the scrobble archive. The archive only counts
+// as covering the year once it holds nearly as many of its plays as the charts do, so a
+// backfill still in progress doesn't produce a wrong answer.
+func (s *Service) busiestDay(start, end time.Time, chartTotal int) *DayCount {
+	if s.archive == nil || chartTotal == 0 || !s.archive.HasData() {
+		return nil
+	}
+
+	r := scrobbles.Range{From: start, To: end.Add(-time.Second), Location: time.UTC}
+	counts, err := s.archive.Counts(r)
+	if err != nil || float64(counts.Scrobbles) < 0.95*float64(chartTotal) {
+		return nil
+	}
+
+	days, err := s.archive.ScrobblesPer("day", r)
+	if err != nil {
+		return nil
+	}
+
+	var busiest *DayCount
+	for _, d := range days {
+		if busiest == nil || d.Count > busiest.Scrobbles {
+			busiest = &DayCount{Date: d.Period, Scrobbles: d.Count}
+		}
+	}
+	return busiest
+}
+
+// genreShift compares genre shares of the top artists in each half of the year
+func (s *Service) genreShift(ctx context.Context, halves [2]tally) []GenreShift {
+	shares := [2]map[string]float64{{}, {}}
+	for i, half := range halves {
+		top := half.top(50, nil)
+		artists := make([]lastfm.TopArtist, len(top))
+		for j, e := range top {
+			artists[j] = lastfm.TopArtist{Name: e.Name, PlayCount: strconv.Itoa(e.PlayCount)}
+		}
+		for _, g := range s.genres.Genres(ctx, artists, 20) {
+			shares[i][g.Name] = g.Share
+		}
+	}
+
+	shifts := make([]GenreShift, 0)
+	seen := make(map[string]bool)
+	for _, m := range shares {
+		for name := range m {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			first, second := shares[0][name], shares[1][name]
+			shifts = append(shifts, GenreShift{
+				Name:       name,
+				FirstHalf:  first,
+				SecondHalf: second,
+				Change:     math.Round((second-first)*10000) / 10000,
+			})
+		}
+	}
+
+	sort.Slice(shifts, func(i, j int) bool {
+		a, b := math.Abs(shifts[i].Change), math.Abs(shifts[j].Change)
+		if a != b {
+			return a > b
+		}
+		return shifts[i].Name < shifts[j].Name
+	})
+	if len(shifts) > topLimit {
+		shifts = shifts[:topLimit]
+	}
+	return shifts
+}
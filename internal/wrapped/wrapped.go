@@ -0,0 +1,208 @@
+package wrapped
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"tringldev-server/internal/config"
+	"tringldev-server/internal/genres"
+	"tringldev-server/internal/lastfm"
+	"tringldev-server/internal/scrobbles"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// Reports for the current year are rebuilt this often, past years never are
+	currentYearTTL = 6 * time.Hour
+
+	// How long a request waits for a report to build before being told to come back later
+	buildWait = 20 * time.Second
+
+	// Upper bound on building a whole year from scratch
+	buildTimeout = 10 * time.Minute
+
+	// Pause between uncached chart requests, a year needs over 150 of them
+	chartDelay = 200 * time.Millisecond
+
+	// Last.fm's first year, nothing can have been scrobbled before it
+	firstYear = 2002
+)
+
+var (
+	ErrInvalidYear = errors.New("year must be between 2002 and the current year")
+	// The report is still being built in the background
+	ErrPending = errors.New("report is being built, try again shortly")
+)
+
+// Service builds yearly listening reports from Last.fm's weekly charts. Charts for weeks that
+// are over, and reports for years that are over, are stored in SQLite and never refetched.
+type Service struct {
+	config  *config.Config
+	db      *sql.DB
+	lastfm  *lastfm.Service
+	genres  *genres.Service
+	archive *scrobbles.Archive
+
+	group singleflight.Group
+}
+
+func NewService(cfg *config.Config, db *sql.DB, service *lastfm.Service, genreService *genres.Service, archive *scrobbles.Archive) (*Service, error) {
+	query := `
+	CREATE TABLE IF NOT EXISTS chart_cache (
+		kind TEXT NOT NULL,
+		from_ts INTEGER NOT NULL,
+		to_ts INTEGER NOT NULL,
+		entries TEXT NOT NULL,
+		PRIMARY KEY (kind, from_ts, to_ts)
+	);
+	CREATE TABLE IF NOT EXISTS wrapped_reports (
+		year INTEGER PRIMARY KEY,
+		report TEXT NOT NULL,
+		generated_at INTEGER NOT NULL
+	);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create wrapped tables: %w", err)
+	}
+
+	return &Service{
+		config:  cfg,
+		db:      db,
+		lastfm:  service,
+		genres:  genreService,
+		archive: archive,
+	}, nil
+}
+
+// Report returns the report for year. A missing or outdated report is built in the
+// background; if that takes longer than buildWait, ErrPending is returned, or the outdated
+// report if there is one.
+func (s *Service) Report(ctx context.Context, year int) (*Report, error) {
+	now := time.Now().UTC()
+	if year < firstYear || year > now.Year() {
+		return nil, ErrInvalidYear
+	}
+
+	stored, generatedAt, err := s.load(year)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil && (stored.Complete || now.Sub(generatedAt) < currentYearTTL) {
+		return stored, nil
+	}
+
+	// The build outlives the request that started it, so later requests can pick it up
+	done := s.group.DoChan(fmt.Sprint(year), func() (any, error) {
+		buildCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), buildTimeout)
+		defer cancel()
+
+		report, err := s.build(buildCtx, year)
+		if err != nil {
+			log.Printf("Failed to build wrapped report for %d: %v\n", year, err)
+			return nil, err
+		}
+		if err := s.save(report); err != nil {
+			log.Printf("Failed to store wrapped report for %d: %v\n", year, err)
+		}
+		return report, nil
+	})
+
+	if stored != nil {
+		return stored, nil
+	}
+
+	select {
+	case result := <-done:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*Report), nil
+	case <-time.After(buildWait):
+		return nil, ErrPending
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Service) load(year int) (*Report, time.Time, error) {
+	var raw string
+	var generatedAt int64
+	err := s.db.QueryRow("SELECT report, generated_at FROM wrapped_reports WHERE year = ?", year).Scan(&raw, &generatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load wrapped report: %w", err)
+	}
+
+	report := &Report{}
+	if err := json.Unmarshal([]byte(raw), report); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode wrapped report: %w", err)
+	}
+	return report, time.Unix(generatedAt, 0), nil
+}
+
+func (s *Service) save(report *Report) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO wrapped_reports (year, report, generated_at) VALUES (?, ?, ?)
+		ON CONFLICT(year) DO UPDATE SET report = excluded.report, generated_at = excluded.generated_at`,
+		report.Year, string(raw), report.GeneratedAt.Unix())
+	return err
+}
+
+// chart returns an artist, track or album chart, from SQLite when the range has been fetched
+// before. Only ranges that are over are stored, since their plays can no longer change.
+func (s *Service) chart(ctx context.Context, kind string, from, to time.Time) ([]lastfm.ChartEntry, error) {
+	var raw string
+	err := s.db.QueryRow("SELECT entries FROM chart_cache WHERE kind = ? AND from_ts = ? AND to_ts = ?",
+		kind, from.Unix(), to.Unix()).Scan(&raw)
+	if err == nil {
+		var entries []lastfm.ChartEntry
+		if err := json.Unmarshal([]byte(raw), &entries); err == nil {
+			return entries, nil
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read chart cache: %w", err)
+	}
+
+	var entries []lastfm.ChartEntry
+	switch kind {
+	case "artist":
+		entries, err = s.lastfm.GetWeeklyArtistChart(ctx, from, to)
+	case "track":
+		entries, err = s.lastfm.GetWeeklyTrackChart(ctx, from, to)
+	case "album":
+		entries, err = s.lastfm.GetWeeklyAlbumChart(ctx, from, to)
+	default:
+		return nil, fmt.Errorf("unknown chart kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if to.Before(time.Now()) {
+		if raw, err := json.Marshal(entries); err == nil {
+			_, err = s.db.Exec("INSERT OR REPLACE INTO chart_cache (kind, from_ts, to_ts, entries) VALUES (?, ?, ?, ?)",
+				kind, from.Unix(), to.Unix(), string(raw))
+			if err != nil {
+				log.Printf("Failed to cache %s chart: %v\n", kind, err)
+			}
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(chartDelay):
+	}
+	return entries, nil
+}